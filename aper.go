@@ -6,21 +6,30 @@ package aper
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"path"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/omec-project/aper/logger"
 )
 
 const (
 	PRESENT = "Present"
+	// maxZeroWidthElements is the most elements of a SEQUENCE OF which are encoded in no bits, such
+	// as NULL, as a few length octets would otherwise decode to any number of them.
+	maxZeroWidthElements = 65536
 )
 
 type perBitData struct {
 	bytes      []byte
 	byteOffset uint64
 	bitsOffset uint
+	ancestors  []sequenceAncestor // the enclosing SEQUENCE and SET values, innermost last.
 }
 
 func perTrace(level int, s string) {
@@ -85,6 +94,11 @@ func GetBitsValue(srcBytes []byte, bitsOffset uint, numBits uint) (value uint64,
 	return
 }
 
+// bitPosition returns the number of bits which have been decoded.
+func (pd *perBitData) bitPosition() uint64 {
+	return pd.byteOffset*8 + uint64(pd.bitsOffset)
+}
+
 func (pd *perBitData) bitCarry() {
 	pd.byteOffset += uint64(pd.bitsOffset >> 3)
 	pd.bitsOffset = pd.bitsOffset & 0x07
@@ -193,6 +207,28 @@ func (pd *perBitData) parseNormallySmallNonNegativeWholeNumber() (value uint64,
 	return
 }
 
+func (pd *perBitData) parseNormallySmallLength() (value uint64, err error) {
+	var notSmallFlag uint64
+	if notSmallFlag, err = pd.getBitsValue(1); err != nil {
+		return
+	}
+	if notSmallFlag == 1 {
+		var repeat bool
+		if value, err = pd.parseLength(-1, &repeat); err != nil {
+			return
+		}
+		if repeat || value == 0 {
+			err = fmt.Errorf("normally small length is out of range: %d", value)
+		}
+	} else {
+		if value, err = pd.getBitsValue(6); err != nil {
+			return
+		}
+		value++
+	}
+	return
+}
+
 func (pd *perBitData) parseLength(sizeRange int64, repeat *bool) (value uint64, err error) {
 	*repeat = false
 	if sizeRange <= 65536 && sizeRange > 0 {
@@ -280,7 +316,10 @@ func (pd *perBitData) parseBitString(extensed bool, lowerBoundPtr *int64, upperB
 		} else {
 			rawLength = length
 		}
-		rawLength += uint64(lb)
+		if sizeRange > 0 {
+			// only a constrained length is the offset from lb
+			rawLength += uint64(lb)
+		}
 		perTrace(2, fmt.Sprintf("Decoding BIT STRING size %d", rawLength))
 		if rawLength == 0 {
 			return bitString, nil
@@ -363,7 +402,10 @@ func (pd *perBitData) parseOctetString(extensed bool, lowerBoundPtr *int64, uppe
 		} else {
 			rawLength = length
 		}
-		rawLength += uint64(lb)
+		if sizeRange > 0 {
+			// only a constrained length is the offset from lb
+			rawLength += uint64(lb)
+		}
 		perTrace(2, fmt.Sprintf("Decoding OCTET STRING size %d", rawLength))
 		if rawLength == 0 {
 			return octetString, nil
@@ -387,6 +429,95 @@ func (pd *perBitData) parseOctetString(extensed bool, lowerBoundPtr *int64, uppe
 	return octetString, nil
 }
 
+func (pd *perBitData) parseCharacterString(extensed bool, params fieldParameters) (string, error) {
+	ce, err := getCharacterEncoding(params.stringType, params.permittedAlphabet)
+	if err != nil {
+		return "", err
+	}
+	var lb, ub int64 = 0, -1
+	if !extensed {
+		if params.sizeLowerBound != nil {
+			lb = *params.sizeLowerBound
+		}
+		if params.sizeUpperBound != nil {
+			ub = *params.sizeUpperBound
+		}
+	}
+	// X.691 30.5.7: small strings are not octet-aligned
+	aligned := ub == -1 || uint64(ub)*uint64(ce.bits) > 16
+	perTrace(2, fmt.Sprintf("Decoding %s with %d bits per character", params.stringType, ce.bits))
+
+	var chars []rune
+	getChars := func(length uint64) error {
+		if length == 0 {
+			return nil
+		} else if aligned {
+			if err := pd.parseAlignBits(); err != nil {
+				return err
+			}
+		}
+		for ; length > 0; length-- {
+			code, err := pd.getBitsValue(ce.bits)
+			if err != nil {
+				return err
+			}
+			c, err := ce.decode(code)
+			if err != nil {
+				return fmt.Errorf("decode %s: %v", params.stringType, err)
+			}
+			chars = append(chars, c)
+		}
+		return nil
+	}
+
+	if ub != -1 && ub < 65536 {
+		length := uint64(lb)
+		if lb != ub {
+			rawLength, err := pd.parseConstraintValue(ub - lb + 1)
+			if err != nil {
+				return "", err
+			}
+			length += rawLength
+		}
+		if length > uint64(ub) {
+			return "", fmt.Errorf("%s length(%d) is over upperbound(%d)", params.stringType, length, ub)
+		} else if err := getChars(length); err != nil {
+			return "", err
+		}
+	} else {
+		repeat := true
+		for repeat {
+			length, err := pd.parseLength(-1, &repeat)
+			if err != nil {
+				return "", err
+			} else if err := getChars(length); err != nil {
+				return "", err
+			}
+		}
+		if len(chars) < int(lb) {
+			return "", fmt.Errorf("%s length(%d) is under lowerbound(%d)", params.stringType, len(chars), lb)
+		}
+	}
+	value := string(chars)
+	perTrace(2, fmt.Sprintf("Decoded %s : \"%s\"", params.stringType, value))
+	return value, nil
+}
+
+func (pd *perBitData) parseUTF8String(params fieldParameters) (string, error) {
+	octetString, err := pd.parseOctetString(false, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	value := string(octetString)
+	if !utf8.ValidString(value) {
+		return "", fmt.Errorf("UTF8String 0x%0x is not valid UTF-8", []byte(octetString))
+	} else if err := checkUTF8StringSize(value, params); err != nil {
+		return "", err
+	}
+	perTrace(2, fmt.Sprintf("Decoded UTF8String : \"%s\"", value))
+	return value, nil
+}
+
 func (pd *perBitData) parseBool() (value bool, err error) {
 	perTrace(3, "Decoding BOOLEAN Value")
 	bit, err1 := pd.getBitsValue(1)
@@ -474,6 +605,9 @@ func (pd *perBitData) parseInteger(extensed bool, lowerBoundPtr *int64, upperBou
 		}
 	}
 	perTrace(2, fmt.Sprintf("Decoding INTEGER Length with %d bytes", rawLength))
+	if rawLength > 8 {
+		return int64(0), fmt.Errorf("INTEGER length(%d) is over 8 bytes of int64", rawLength)
+	}
 
 	if rawValue, err := pd.getBitsValue(rawLength * 8); err != nil {
 		return int64(0), err
@@ -486,7 +620,145 @@ func (pd *perBitData) parseInteger(extensed bool, lowerBoundPtr *int64, upperBou
 		}
 		return int64(rawValue) + lb, nil
 	} else {
-		return int64(rawValue) + lb, nil
+		// the offset from lb may exceed MaxInt64, in which case the sum wraps below lb
+		value := lb + int64(rawValue)
+		if value < lb {
+			return int64(0), fmt.Errorf("INTEGER value %d+%d overflows int64", lb, rawValue)
+		} else if valueRange > 0 && value > ub {
+			return int64(0), fmt.Errorf("INTEGER value %d is larger than upperbound(%d)", value, ub)
+		}
+		return value, nil
+	}
+}
+
+// parseBigInteger decodes an INTEGER of any size as a constrained, semi-constrained or
+// unconstrained whole number (X.691 13).
+func (pd *perBitData) parseBigInteger(extensed bool, lb *big.Int, ub *big.Int) (*big.Int, error) {
+	if extensed {
+		lb, ub = nil, nil
+	}
+	if lb != nil && ub != nil {
+		valueRange := new(big.Int).Sub(ub, lb)
+		valueRange.Add(valueRange, big.NewInt(1))
+		if valueRange.Sign() <= 0 {
+			return nil, fmt.Errorf("integer value range(%s..%s) is empty", lb, ub)
+		} else if valueRange.Cmp(big.NewInt(65536)) <= 0 {
+			var offset uint64
+			if valueRange.Int64() > 1 {
+				var err error
+				if offset, err = pd.parseConstraintValue(valueRange.Int64()); err != nil {
+					return nil, err
+				}
+			}
+			return new(big.Int).Add(lb, new(big.Int).SetUint64(offset)), nil
+		}
+		maxLength := int64(len(valueRange.Sub(valueRange, big.NewInt(1)).Bytes()))
+		length, err := pd.parseConstraintValue(maxLength)
+		if err != nil {
+			return nil, err
+		} else if err := pd.parseAlignBits(); err != nil {
+			return nil, err
+		}
+		contents, err := pd.getBitString(uint(length+1) * 8)
+		if err != nil {
+			return nil, err
+		}
+		value := new(big.Int).Add(lb, new(big.Int).SetBytes(contents))
+		if value.Cmp(ub) > 0 {
+			return nil, fmt.Errorf("integer value %s is larger than upperbound %s", value, ub)
+		}
+		return value, nil
+	}
+
+	repeat := false
+	length, err := pd.parseLength(-1, &repeat)
+	if err != nil {
+		return nil, err
+	} else if repeat || length == 0 {
+		return nil, fmt.Errorf("invalid integer length %d", length)
+	}
+	contents, err := pd.getBitString(uint(length) * 8)
+	if err != nil {
+		return nil, err
+	} else if lb != nil {
+		return new(big.Int).Add(lb, new(big.Int).SetBytes(contents)), nil
+	}
+	return parseSignedBigInt(contents), nil
+}
+
+// parseReal decodes the length-prefixed contents octets of a REAL (X.691 15),
+// in any of the binary, decimal or special value forms of X.690 8.5.
+func (pd *perBitData) parseReal() (float64, error) {
+	contents, err := pd.parseOctetString(false, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	perTrace(3, fmt.Sprintf("Decoding REAL contents octets : 0x%0x", contents))
+	if len(contents) == 0 {
+		return 0, nil
+	}
+	firstOctet := contents[0]
+	switch {
+	case firstOctet&0x80 != 0:
+		// binary encoding
+		var baseBits int64
+		switch (firstOctet >> 4) & 0x3 {
+		case 0:
+			baseBits = 1
+		case 1:
+			baseBits = 3
+		case 2:
+			baseBits = 4
+		default:
+			return 0, fmt.Errorf("REAL base is reserved")
+		}
+		scaleFactor := int64(firstOctet>>2) & 0x3
+		exponentOffset, exponentLength := 1, int(firstOctet&0x3)+1
+		if exponentLength == 4 {
+			if len(contents) < 2 {
+				return 0, fmt.Errorf("REAL exponent length is missing")
+			}
+			exponentOffset, exponentLength = 2, int(contents[1])
+		}
+		if exponentLength == 0 || exponentLength > 8 || exponentOffset+exponentLength > len(contents) {
+			return 0, fmt.Errorf("REAL exponent length is out of range: %d", exponentLength)
+		}
+		exponent := int64(int8(contents[exponentOffset]))
+		for _, octet := range contents[exponentOffset+1 : exponentOffset+exponentLength] {
+			exponent = exponent<<8 | int64(octet)
+		}
+		// any exponent beyond this range overflows or underflows a float64
+		exponent = max(min(exponent, 1<<24), -(1 << 24))
+		mantissa := new(big.Float).SetInt(new(big.Int).SetBytes(contents[exponentOffset+exponentLength:]))
+		value, _ := mantissa.SetMantExp(mantissa, int(exponent*baseBits+scaleFactor)).Float64()
+		if firstOctet&0x40 != 0 {
+			value = -value
+		}
+		return value, nil
+	case firstOctet&0xc0 == 0x40:
+		// special real value
+		switch firstOctet {
+		case 0x40:
+			return math.Inf(1), nil
+		case 0x41:
+			return math.Inf(-1), nil
+		case 0x42:
+			return math.NaN(), nil
+		case 0x43:
+			return math.Copysign(0, -1), nil
+		}
+		return 0, fmt.Errorf("REAL special value is reserved: 0x%0x", firstOctet)
+	default:
+		// decimal encoding in ISO 6093 NR1, NR2 or NR3 form
+		if form := firstOctet & 0x3f; form < 1 || form > 3 {
+			return 0, fmt.Errorf("REAL decimal form is reserved: %d", form)
+		}
+		number := strings.TrimSpace(strings.Replace(string(contents[1:]), ",", ".", 1))
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("REAL decimal value is invalid: %q", number)
+		}
+		return value, nil
 	}
 }
 
@@ -523,10 +795,9 @@ func (pd *perBitData) parseEnumerated(extensed bool, lowerBoundPtr *int64, upper
 func (pd *perBitData) parseSequenceOf(sizeExtensed bool, params fieldParameters, sliceType reflect.Type) (
 	reflect.Value, error,
 ) {
-	var sliceContent reflect.Value
 	var lb int64 = 0
 	var sizeRange int64
-	if params.sizeLowerBound != nil && *params.sizeLowerBound < 65536 {
+	if !sizeExtensed && params.sizeLowerBound != nil && *params.sizeLowerBound < 65536 {
 		lb = *params.sizeLowerBound
 	}
 	if !sizeExtensed && params.sizeUpperBound != nil && *params.sizeUpperBound < 65536 {
@@ -538,49 +809,62 @@ func (pd *perBitData) parseSequenceOf(sizeExtensed bool, params fieldParameters,
 		perTrace(3, fmt.Sprintf("Decoding Length of \"SEQUENCE OF\" with Semi-Constraint Range(%d..)", lb))
 	}
 
-	var numElements uint64
-	if sizeRange > 1 {
-		if numElementsTmp, err := pd.parseConstraintValue(sizeRange); err != nil {
-			logger.AperLog.Warnf("Parse Constraint Value failed: %+v", err)
-		} else {
-			numElements = numElementsTmp
-		}
-		numElements += uint64(lb)
-	} else if sizeRange == 1 {
-		numElements += uint64(lb)
-	} else {
-		if err := pd.parseAlignBits(); err != nil {
-			return sliceContent, err
+	params = getElementParameters(params)
+	sliceContent := reflect.MakeSlice(sliceType, 0, 0)
+	parseElements := func(numElements uint64) error {
+		perTrace(2, fmt.Sprintf("Decoding  \"SEQUENCE OF\" struct %s with len(%d)", sliceType.Elem().Name(), numElements))
+		for ; numElements > 0; numElements-- {
+			element := reflect.New(sliceType.Elem()).Elem()
+			offset := pd.bitPosition()
+			if err := parseField(element, pd, params); err != nil {
+				return err
+			} else if pd.bitPosition() == offset && sliceContent.Len() >= maxZeroWidthElements {
+				return fmt.Errorf("sequence of has more than %d elements of no bits", maxZeroWidthElements)
+			}
+			sliceContent = reflect.Append(sliceContent, element)
 		}
-		if pd.byteOffset >= uint64(len(pd.bytes)) {
-			return sliceContent, fmt.Errorf("per data out of range")
+		return nil
+	}
+
+	if sizeRange > 0 {
+		var numElements uint64
+		if sizeRange > 1 {
+			if numElementsTmp, err := pd.parseConstraintValue(sizeRange); err != nil {
+				return sliceContent, err
+			} else {
+				numElements = numElementsTmp
+			}
 		}
-		numElements = uint64(pd.bytes[pd.byteOffset])
-		pd.byteOffset++
-		perTrace(1, perBitLog(8, pd.byteOffset, pd.bitsOffset, numElements))
-	}
-	perTrace(2, fmt.Sprintf("Decoding  \"SEQUENCE OF\" struct %s with len(%d)", sliceType.Elem().Name(), numElements))
-	params.sizeExtensible = false
-	params.sizeUpperBound = nil
-	params.sizeLowerBound = nil
-	intNumElements := int(numElements)
-	sliceContent = reflect.MakeSlice(sliceType, intNumElements, intNumElements)
-	for i := 0; i < intNumElements; i++ {
-		err := parseField(sliceContent.Index(i), pd, params)
+		numElements += uint64(lb)
+		return sliceContent, parseElements(numElements)
+	}
+	// semi-constrained length, fragmented in units of 16K elements (X.691 11.9.3.8)
+	for repeat := true; repeat; {
+		numElements, err := pd.parseLength(-1, &repeat)
 		if err != nil {
 			return sliceContent, err
+		} else if err := parseElements(numElements); err != nil {
+			return sliceContent, err
 		}
 	}
+	if int64(sliceContent.Len()) < lb {
+		return sliceContent, fmt.Errorf("sequence of size %d is lower than lowerbound %d", sliceContent.Len(), lb)
+	}
 	return sliceContent, nil
 }
 
 func (pd *perBitData) getChoiceIndex(extensed bool, upperBoundPtr *int64) (present int, err error) {
-	if extensed {
-		err = fmt.Errorf("unsupport value of CHOICE type is in Extensed")
-	} else if upperBoundPtr == nil {
+	if upperBoundPtr == nil {
 		err = fmt.Errorf("the upper bound of CHIOCE is missing")
 	} else if ub := *upperBoundPtr; ub < 0 {
 		err = fmt.Errorf("the upper bound of CHIOCE is negative")
+	} else if extensed {
+		if rawChoice, err1 := pd.parseNormallySmallNonNegativeWholeNumber(); err1 != nil {
+			err = err1
+		} else {
+			perTrace(2, fmt.Sprintf("Decoded Present index of CHOICE is %d + %d + 2", rawChoice, ub))
+			present = int(rawChoice) + int(ub) + 2
+		}
 	} else if rawChoice, err1 := pd.parseConstraintValue(ub + 1); err1 != nil {
 		err = err1
 	} else {
@@ -590,11 +874,30 @@ func (pd *perBitData) getChoiceIndex(extensed bool, upperBoundPtr *int64) (prese
 	return
 }
 
-func getReferenceFieldValue(v reflect.Value) (value int64, err error) {
+// getReferenceFieldValue returns the value of the reference field v of an open type, which is
+// an INTEGER or ENUMERATED, possibly in a CHOICE. A global OBJECT IDENTIFIER, such as the global
+// PrivateIE-ID, has no value in an object set, so global is true for it instead.
+func getReferenceFieldValue(v reflect.Value) (value int64, global bool, err error) {
 	fieldType := v.Type()
+	if fieldType == ObjectIdentifierType {
+		global = true
+		return
+	}
 	switch v.Kind() {
-	case reflect.Int, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		value = v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// including ENUMERATED
+		if v.Uint() > math.MaxInt64 {
+			err = fmt.Errorf("referenceField value %d is larger than MaxInt64", v.Uint())
+		}
+		value = int64(v.Uint())
+	case reflect.Ptr:
+		if v.IsNil() {
+			err = fmt.Errorf("referenceField value is nil")
+		} else {
+			value, global, err = getReferenceFieldValue(v.Elem())
+		}
 	case reflect.Struct:
 		if fieldType.Field(0).Name == PRESENT {
 			present := int(v.Field(0).Int())
@@ -603,45 +906,112 @@ func getReferenceFieldValue(v reflect.Value) (value int64, err error) {
 			} else if present >= fieldType.NumField() {
 				err = fmt.Errorf("present is bigger than number of struct field")
 			} else {
-				value, err = getReferenceFieldValue(v.Field(present))
+				value, global, err = getReferenceFieldValue(v.Field(present))
 			}
 		} else {
-			value, err = getReferenceFieldValue(v.Field(0))
+			value, global, err = getReferenceFieldValue(v.Field(0))
 		}
 	default:
-		err = fmt.Errorf("openType reference only support INTEGER")
+		err = fmt.Errorf("openType reference only support INTEGER and ENUMERATED")
 	}
 	return
 }
 
-func (pd *perBitData) parseOpenType(skip bool, v reflect.Value, params fieldParameters) error {
-	pdOpenType := &perBitData{[]byte(""), 0, 0}
+// getReferenceField returns the field which the referenceFieldName of the open type field being
+// encoded or decoded in the innermost SEQUENCE of ancestors refers to. The name is either a
+// preceding field of the same SEQUENCE, or a component relation path (X.682 10.7): "@.id" is a
+// field of the same SEQUENCE, each further "." moves to the enclosing SEQUENCE ("@..id"), and
+// "@id" starts from the outermost one. The path may continue into nested structs, as in
+// "@..header.id". The field must precede the component of its SEQUENCE which contains the open
+// type in the order of encoding, which is the canonical order for a SET, as it would not be
+// decoded yet otherwise.
+func getReferenceField(ancestors []sequenceAncestor, name string) (reflect.Value, error) {
+	level := 0
+	if path, ok := strings.CutPrefix(name, "@"); ok {
+		level = len(ancestors) - 1
+		if strings.HasPrefix(path, ".") {
+			level = len(path) - len(strings.TrimLeft(path, ".")) - 1
+		}
+		name = strings.TrimLeft(path, ".")
+	}
+	if level >= len(ancestors) {
+		return reflect.Value{}, fmt.Errorf("open type reference %q is out of the enclosing SEQUENCE", name)
+	}
+	ancestor := ancestors[len(ancestors)-1-level]
+	v := ancestor.value
+	for k, component := range strings.Split(name, ".") {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("open type reference %q is absent", name)
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("open type reference %q is not a field of a struct", name)
+		}
+		field, ok := v.Type().FieldByName(component)
+		if !ok || (k == 0 && !ancestor.precedes(field.Index)) {
+			return reflect.Value{}, fmt.Errorf("open type is not reference to the other field in the struct")
+		}
+		v = v.FieldByIndex(field.Index)
+	}
+	return v, nil
+}
+
+// setReferenceFieldValue sets the reference value of the open type field being encoded or decoded
+// in the innermost SEQUENCE of ancestors from the field which its referenceFieldName refers to.
+// A global reference leaves the reference value nil, and the open type is unknown.
+func setReferenceFieldValue(ancestors []sequenceAncestor, params *fieldParameters) error {
+	field, err := getReferenceField(ancestors, params.referenceFieldName)
+	if err != nil {
+		return err
+	}
+	params.referenceFieldValue = nil
+	if referenceFieldValue, global, err := getReferenceFieldValue(field); err != nil {
+		return err
+	} else if params.globalReference = global; !global {
+		params.referenceFieldValue = &referenceFieldValue
+	}
+	return nil
+}
+
+func (pd *perBitData) parseOpenTypeContents() ([]byte, error) {
+	openTypeBytes := []byte("")
 	repeat := false
 	for {
 		var rawLength uint64
 		if rawLengthTmp, err := pd.parseLength(-1, &repeat); err != nil {
-			return err
+			return nil, err
 		} else {
 			rawLength = rawLengthTmp
 		}
 		if rawLength == 0 {
 			break
 		} else if err := pd.parseAlignBits(); err != nil {
-			return err
+			return nil, err
 		}
 		if (rawLength + pd.byteOffset) > uint64(len(pd.bytes)) {
-			return fmt.Errorf("per data out of range ")
+			return nil, fmt.Errorf("per data out of range ")
 		}
-		pdOpenType.bytes = append(pdOpenType.bytes, pd.bytes[pd.byteOffset:pd.byteOffset+rawLength]...)
+		openTypeBytes = append(openTypeBytes, pd.bytes[pd.byteOffset:pd.byteOffset+rawLength]...)
 		pd.byteOffset += rawLength
 
 		if !repeat {
 			if err := pd.parseAlignBits(); err != nil {
-				return err
+				return nil, err
 			}
 			break
 		}
 	}
+	return openTypeBytes, nil
+}
+
+func (pd *perBitData) parseOpenType(skip bool, v reflect.Value, params fieldParameters) error {
+	openTypeBytes, err := pd.parseOpenTypeContents()
+	if err != nil {
+		return err
+	}
+	pdOpenType := &perBitData{openTypeBytes, 0, 0, pd.ancestors}
 	if skip {
 		perTrace(2, fmt.Sprintf("Skip OpenType (len = %d byte)", len(pdOpenType.bytes)))
 		return nil
@@ -653,24 +1023,200 @@ func (pd *perBitData) parseOpenType(skip bool, v reflect.Value, params fieldPara
 	}
 }
 
+// parseRegisteredOpenType decodes an open type into the interface v, with the type
+// registered for its reference value in the object set of v.
+func (pd *perBitData) parseRegisteredOpenType(v reflect.Value, params fieldParameters) error {
+	object, ok, err := getOpenTypeObject(v.Type(), params)
+	if err != nil {
+		return err
+	}
+	openTypeBytes, err := pd.parseOpenTypeContents()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		unknown := reflect.ValueOf(UnknownOpenType(openTypeBytes))
+		if params.globalReference {
+			perTrace(2, "OpenType reference is global")
+			if unknown.Type().AssignableTo(v.Type()) {
+				v.Set(unknown)
+			}
+			return nil
+		}
+		perTrace(2, fmt.Sprintf("OpenType reference value %d is not registered", *params.referenceFieldValue))
+		if !unknown.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("open type reference value %d is not registered for %s", *params.referenceFieldValue,
+				v.Type())
+		}
+		v.Set(unknown)
+		return nil
+	}
+	if !object.valueType.AssignableTo(v.Type()) {
+		return fmt.Errorf("registered open type %s is not assignable to %s", object.valueType, v.Type())
+	}
+	value := reflect.New(object.valueType).Elem()
+	pdOpenType := &perBitData{openTypeBytes, 0, 0, pd.ancestors}
+	perTrace(2, fmt.Sprintf("Decoding OpenType %s with (len = %d byte)", object.valueType, len(openTypeBytes)))
+	if err := parseField(value, pdOpenType, object.params); err != nil {
+		return err
+	}
+	perTrace(2, fmt.Sprintf("Decoded OpenType %s", object.valueType))
+	v.Set(value)
+	return nil
+}
+
+func (pd *perBitData) parseSequenceComponents(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters, indexes []int,
+) error {
+	structType := v.Type()
+	var optionalCount uint
+	var optionalPresents uint64
+
+	for _, i := range indexes {
+		if structParams[i].isOptionalOrDefault() {
+			optionalCount++
+		}
+	}
+	if optionalCount > 0 {
+		if optionalPresentsTmp, err := pd.getBitsValue(optionalCount); err != nil {
+			return err
+		} else {
+			optionalPresents = optionalPresentsTmp
+		}
+		perTrace(2, fmt.Sprintf("optionalPresents is %0b", optionalPresents))
+	}
+
+	for _, i := range indexes {
+		pd.ancestors[len(pd.ancestors)-1].index = fields[i].Index
+		if structParams[i].isOptionalOrDefault() && optionalCount > 0 {
+			optionalCount--
+			if optionalPresents&(1<<optionalCount) == 0 {
+				perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and not present", fields[i].Name, structType))
+				if structParams[i].defaultValue != nil {
+					if err := setDefaultValue(v.FieldByIndex(fields[i].Index), *structParams[i].defaultValue); err != nil {
+						return err
+					}
+				}
+				continue
+			} else {
+				perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and present", fields[i].Name, structType))
+			}
+		}
+		// for open type reference
+		if structParams[i].openType {
+			if err := setReferenceFieldValue(pd.ancestors, &structParams[i]); err != nil {
+				return err
+			}
+		}
+		if err := parseField(v.FieldByIndex(fields[i].Index), pd, structParams[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseExtensionAdditions decodes the extension addition bitmap of a SEQUENCE and
+// the additions it marks as present, each of which is encoded as an open type.
+// Additions beyond the fields known to the struct are kept in its RawExtensions
+// field, or skipped if it has none, and absent additions take their DEFAULT values.
+func (pd *perBitData) parseExtensionAdditions(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters, additions []extensionAddition,
+) error {
+	structType := v.Type()
+	rawExtensionsIndex := getRawExtensionsIndex(fields)
+	numAdditions, err := pd.parseNormallySmallLength()
+	if err != nil {
+		return err
+	}
+	perTrace(2, fmt.Sprintf("Decoding %d bits of extension addition bitmap", numAdditions))
+	presents := make([]bool, numAdditions)
+	for j := range presents {
+		if bit, err1 := pd.getBitsValue(1); err1 != nil {
+			return err1
+		} else {
+			presents[j] = bit == 1
+		}
+	}
+	for j, addition := range additions {
+		if j >= len(presents) || !presents[j] {
+			if err := addition.setDefaultValues(v, fields, structParams); err != nil {
+				return err
+			}
+		}
+	}
+	for j, present := range presents {
+		if !present {
+			continue
+		}
+		if j >= len(additions) {
+			perTrace(2, fmt.Sprintf("Extension addition %d of %s is unknown", j, structType))
+			if rawExtensionsIndex < 0 {
+				if err := pd.parseOpenType(true, reflect.Value{}, fieldParameters{}); err != nil {
+					return err
+				}
+				continue
+			}
+			openTypeBytes, err := pd.parseOpenTypeContents()
+			if err != nil {
+				return err
+			}
+			rawExtensions := v.FieldByIndex(fields[rawExtensionsIndex].Index)
+			rawExtensions.Set(reflect.Append(rawExtensions, reflect.ValueOf(RawExtension{uint64(j), openTypeBytes})))
+			continue
+		}
+		if additions[j].group {
+			perTrace(3, fmt.Sprintf("Extension addition group of %s with %d fields is present", structType,
+				len(additions[j].fields)))
+			groupBytes, err := pd.parseOpenTypeContents()
+			if err != nil {
+				return err
+			}
+			pdGroup := &perBitData{groupBytes, 0, 0, pd.ancestors}
+			if err := pdGroup.parseSequenceComponents(v, fields, structParams, additions[j].fields); err != nil {
+				return err
+			}
+			continue
+		}
+		i := additions[j].fields[0]
+		pd.ancestors[len(pd.ancestors)-1].index = fields[i].Index
+		perTrace(3, fmt.Sprintf("Field \"%s\" in %s is extension addition and present", fields[i].Name, structType))
+		if structParams[i].openType {
+			if err := setReferenceFieldValue(pd.ancestors, &structParams[i]); err != nil {
+				return err
+			}
+		}
+		if err := pd.parseOpenType(false, v.FieldByIndex(fields[i].Index), structParams[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // parseField is the main parsing function. Given a byte slice and an offset
 // into the array, it will try to parse a suitable ASN.1 value out and store it
-// in the given Value. TODO : ObjectIdenfier, handle extension Field
+// in the given Value.
 func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 	fieldType := v.Type()
 
-	// If we have run out of data return error.
-	if pd.byteOffset == uint64(len(pd.bytes)) {
-		return fmt.Errorf("sequence truncated")
-	}
 	if v.Kind() == reflect.Ptr {
 		ptr := reflect.New(fieldType.Elem())
 		v.Set(ptr)
 		return parseField(v.Elem(), pd, params)
 	}
+	if v.Kind() == reflect.Interface && params.openType {
+		return pd.parseRegisteredOpenType(v, params)
+	}
+	// NULL does not consume any data
+	if fieldType == NullType {
+		perTrace(2, "Decoded NULL")
+		return nil
+	}
+	// a field which is encoded in no bits, such as a SEQUENCE of NULL components, may end the
+	// data, so running out of data is only detected when the field reads any bits.
 	sizeExtensible := false
 	valueExtensible := false
-	if params.sizeExtensible {
+	// the size constraint of a UTF8String is not PER-visible
+	if params.sizeExtensible && params.stringType != stringTypeUTF8 {
 		if bitsValue, err1 := pd.getBitsValue(1); err1 != nil {
 			return err1
 		} else if bitsValue != 0 {
@@ -698,7 +1244,23 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 		v.Set(reflect.ValueOf(bitString))
 		return nil
 	case ObjectIdentifierType:
-		return fmt.Errorf("unsupport ObjectIdenfier type")
+		perTrace(2, "Decoding OBJECT IDENTIFIER contents octets")
+		if octetString, err := pd.parseOctetString(false, nil, nil); err != nil {
+			return err
+		} else {
+			v.SetBytes(octetString)
+			perTrace(2, fmt.Sprintf("Decoded OBJECT IDENTIFIER : %s", ObjectIdentifier(octetString)))
+			return nil
+		}
+	case RelativeOIDType:
+		perTrace(2, "Decoding RELATIVE-OID contents octets")
+		if octetString, err := pd.parseOctetString(false, nil, nil); err != nil {
+			return err
+		} else {
+			v.SetBytes(octetString)
+			perTrace(2, fmt.Sprintf("Decoded RELATIVE-OID : %s", RelativeOID(octetString)))
+			return nil
+		}
 	case OctetStringType:
 		if octetString, err := pd.parseOctetString(sizeExtensible, params.sizeLowerBound, params.sizeUpperBound); err != nil {
 			return err
@@ -714,6 +1276,46 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 			v.SetUint(parsedEnum)
 			return nil
 		}
+	case TimeType:
+		timeString, err := pd.parseCharacterString(false, fieldParameters{stringType: stringTypeVisible})
+		if err != nil {
+			return err
+		}
+		v.SetString(timeString)
+		perTrace(2, fmt.Sprintf("Decoded TIME : \"%s\"", timeString))
+		return nil
+	case DateType:
+		var enc dateEncoding
+		if err := parseField(reflect.ValueOf(&enc).Elem(), pd, fieldParameters{}); err != nil {
+			return err
+		}
+		date, err := enc.date()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(date))
+		perTrace(2, fmt.Sprintf("Decoded DATE : %s", date))
+		return nil
+	case bigIntType:
+		parsedInt, err := pd.parseBigInteger(valueExtensible, params.bigValueLowerBound, params.bigValueUpperBound)
+		if err != nil {
+			return err
+		}
+		bigIntOf(v).Set(parsedInt)
+		perTrace(2, fmt.Sprintf("Decoded INTEGER Value: %s", parsedInt))
+		return nil
+	case goTimeType:
+		timeString, err := pd.parseCharacterString(false, fieldParameters{stringType: stringTypeVisible})
+		if err != nil {
+			return err
+		}
+		parsedTime, err := parseTime(timeString, params.timeFormat)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(parsedTime))
+		perTrace(2, fmt.Sprintf("Decoded %s : %s", params.timeFormat, parsedTime))
+		return nil
 	}
 	switch val := v; val.Kind() {
 	case reflect.Bool:
@@ -723,65 +1325,90 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 			val.SetBool(parsedBool)
 			return nil
 		}
-	case reflect.Int, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if parsedInt, err := pd.parseInteger(valueExtensible, params.valueLowerBound, params.valueUpperBound); err != nil {
 			return err
+		} else if val.OverflowInt(parsedInt) {
+			return fmt.Errorf("INTEGER value %d overflows %s", parsedInt, fieldType)
 		} else {
 			val.SetInt(parsedInt)
 			perTrace(2, fmt.Sprintf("Decoded INTEGER Value: %d", parsedInt))
 			return nil
 		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsedInt, err := pd.parseBigInteger(valueExtensible, params.bigValueLowerBound, params.bigValueUpperBound)
+		if err != nil {
+			return err
+		} else if !parsedInt.IsUint64() || val.OverflowUint(parsedInt.Uint64()) {
+			return fmt.Errorf("INTEGER value %s overflows %s", parsedInt, fieldType)
+		}
+		val.SetUint(parsedInt.Uint64())
+		perTrace(2, fmt.Sprintf("Decoded INTEGER Value: %s", parsedInt))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if parsedReal, err := pd.parseReal(); err != nil {
+			return err
+		} else if val.OverflowFloat(parsedReal) {
+			return fmt.Errorf("REAL value %g overflows %s", parsedReal, fieldType)
+		} else {
+			val.SetFloat(parsedReal)
+			perTrace(2, fmt.Sprintf("Decoded REAL Value: %g", parsedReal))
+			return nil
+		}
 	case reflect.Struct:
 
 		structType := fieldType
+		structFields := getStructFields(structType)
 		var structParams []fieldParameters
-		var optionalCount uint
-		var optionalPresents uint64
 
-		// pass tag for optional
-		for i := 0; i < structType.NumField(); i++ {
-			if structType.Field(i).PkgPath != "" {
-				return fmt.Errorf("struct contains unexported fields : %s", structType.Field(i).PkgPath)
+		for _, field := range structFields {
+			if field.PkgPath != "" {
+				return fmt.Errorf("struct contains unexported fields : %s", field.PkgPath)
 			}
-			tempParams := parseFieldParameters(structType.Field(i).Tag.Get("aper"))
-			// for optional flag
-			if tempParams.optional {
-				optionalCount++
+			tempParams := parseFieldParameters(field.Tag.Get("aper"))
+			if tempParams.defaultValue != nil {
+				if _, err := getDefaultValue(field.Type, *tempParams.defaultValue); err != nil {
+					return err
+				}
 			}
 			structParams = append(structParams, tempParams)
 		}
 
-		if optionalCount > 0 {
-			if optionalPresentsTmp, err := pd.getBitsValue(optionalCount); err != nil {
-				return err
-			} else {
-				optionalPresents = optionalPresentsTmp
-			}
-			perTrace(2, fmt.Sprintf("optionalPresents is %0b", optionalPresents))
-		}
-
 		// CHOICE or OpenType
 		if structType.NumField() > 0 && structType.Field(0).Name == PRESENT {
 			var present int = 0
 			if params.openType {
-				if params.referenceFieldValue == nil {
+				if params.referenceFieldValue == nil && !params.globalReference {
 					return fmt.Errorf("openType reference value is empty")
 				}
-				refValue := *params.referenceFieldValue
 
 				for j, param := range structParams {
-					if j == 0 {
+					if j == 0 || params.globalReference {
 						continue
 					}
-					if param.referenceFieldValue != nil && *param.referenceFieldValue == refValue {
+					if param.referenceFieldValue != nil && *param.referenceFieldValue == *params.referenceFieldValue {
 						present = j
 						break
 					}
 				}
 				if present == 0 {
-					val.Field(0).SetInt(0)
+					for j := 1; j < structType.NumField(); j++ {
+						if structType.Field(j).Type == UnknownOpenTypeType {
+							present = j
+							break
+						}
+					}
+					val.Field(0).SetInt(int64(present))
 					perTrace(2, "OpenType reference value does not match any field")
-					return pd.parseOpenType(true, reflect.Value{}, fieldParameters{})
+					if present == 0 {
+						return pd.parseOpenType(true, reflect.Value{}, fieldParameters{})
+					}
+					openTypeBytes, err := pd.parseOpenTypeContents()
+					if err != nil {
+						return err
+					}
+					val.Field(present).SetBytes(openTypeBytes)
+					return nil
 				} else if present >= structType.NumField() {
 					return fmt.Errorf("openType Present is bigger than number of struct field")
 				} else {
@@ -795,53 +1422,74 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 				} else {
 					present = presentTmp
 				}
+				rawExtensionsIndex := getRawExtensionsIndex(structFields)
+				if valueExtensible && (present >= structType.NumField() ||
+					(rawExtensionsIndex > 0 && present >= rawExtensionsIndex)) {
+					perTrace(2, "CHOICE extension index does not match any field")
+					if rawExtensionsIndex <= 0 {
+						val.Field(0).SetInt(0)
+						return pd.parseOpenType(true, reflect.Value{}, fieldParameters{})
+					}
+					openTypeBytes, err := pd.parseOpenTypeContents()
+					if err != nil {
+						return err
+					}
+					val.Field(0).SetInt(int64(rawExtensionsIndex))
+					val.Field(rawExtensionsIndex).Set(reflect.ValueOf(RawExtensions{
+						{Index: uint64(present) - uint64(*params.valueUpperBound) - 2, Bytes: openTypeBytes},
+					}))
+					return nil
+				}
 				val.Field(0).SetInt(int64(present))
 				if present == 0 {
 					return fmt.Errorf("choice present is 0 (present's field number)")
 				} else if present >= structType.NumField() {
 					return fmt.Errorf("choice present is bigger than number of struct field")
+				} else if valueExtensible {
+					// alternatives outside the extension root are encoded as open types
+					return pd.parseOpenType(false, val.Field(present), structParams[present])
 				} else {
 					return parseField(val.Field(present), pd, structParams[present])
 				}
 			}
 		}
 
-		for i := 0; i < structType.NumField(); i++ {
-			if structParams[i].optional && optionalCount > 0 {
-				optionalCount--
-				if optionalPresents&(1<<optionalCount) == 0 {
-					perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and not present", structType.Field(i).Name, structType))
-					continue
-				} else {
-					perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and present", structType.Field(i).Name, structType))
-				}
-			}
-			// for open type reference
-			if structParams[i].openType {
-				fieldName := structParams[i].referenceFieldName
-				var index int
-				for index = 0; index < i; index++ {
-					if structType.Field(index).Name == fieldName {
-						break
-					}
-				}
-				if index == i {
-					return fmt.Errorf("open type is not reference to the other field in the struct")
-				}
-				structParams[i].referenceFieldValue = new(int64)
-				if referenceFieldValue, err := getReferenceFieldValue(val.Field(index)); err != nil {
-					return err
-				} else {
-					*structParams[i].referenceFieldValue = referenceFieldValue
-				}
+		root, additions, err := splitExtensionAdditions(structFields, structParams)
+		if err != nil {
+			return err
+		} else if len(additions) > 0 && !params.valueExtensible {
+			return fmt.Errorf("extension addition in non-extensible SEQUENCE %s", structType)
+		}
+		if params.set {
+			if err := sortSetComponents(structType, structFields, structParams, root); err != nil {
+				return err
 			}
-			if err := parseField(val.Field(i), pd, structParams[i]); err != nil {
+		}
+		pd.ancestors = append(pd.ancestors, newSequenceAncestor(val, structFields, root, params.set))
+		defer func() { pd.ancestors = pd.ancestors[:len(pd.ancestors)-1] }()
+		if err := pd.parseSequenceComponents(val, structFields, structParams, root); err != nil {
+			return err
+		}
+		if valueExtensible {
+			return pd.parseExtensionAdditions(val, structFields, structParams, additions)
+		}
+		for _, addition := range additions {
+			if err := addition.setDefaultValues(val, structFields, structParams); err != nil {
 				return err
 			}
 		}
 		return nil
 	case reflect.Slice:
 		sliceType := fieldType
+		if sliceType.Elem().Kind() == reflect.Uint8 {
+			// any slice of bytes is an OCTET STRING rather than a SEQUENCE OF INTEGER
+			octetString, err := pd.parseOctetString(sizeExtensible, params.sizeLowerBound, params.sizeUpperBound)
+			if err != nil {
+				return err
+			}
+			val.SetBytes(octetString)
+			return nil
+		}
 		if newSlice, err := pd.parseSequenceOf(sizeExtensible, params, sliceType); err != nil {
 			return err
 		} else {
@@ -849,6 +1497,21 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 			return nil
 		}
 	case reflect.String:
+		if params.stringType.isKnownMultiplier() {
+			characterString, err := pd.parseCharacterString(sizeExtensible, params)
+			if err != nil {
+				return err
+			}
+			val.SetString(characterString)
+			return nil
+		} else if params.stringType == stringTypeUTF8 {
+			utf8String, err := pd.parseUTF8String(params)
+			if err != nil {
+				return err
+			}
+			val.SetString(utf8String)
+			return nil
+		}
 		perTrace(2, "Decoding PrintableString using Octet String decoding method")
 
 		if octetString, err := pd.parseOctetString(sizeExtensible, params.sizeLowerBound, params.sizeUpperBound); err != nil {
@@ -868,30 +1531,70 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 // Because Unmarshal uses the reflect package, the structs
 // being written to must use upper case field names.
 //
-// An ASN.1 INTEGER can be written to an int, int32, int64,
+// An ASN.1 INTEGER can be written to an int, int8, int16, int32, int64, uint,
+// uint8, uint16, uint32, uint64 or *big.Int.
 // If the encoded value does not fit in the Go type,
-// Unmarshal returns a parse error.
+// Unmarshal returns a parse error. The valueLB and valueUB of a *big.Int
+// may be of any size, and those of an unsigned integer up to MaxUint64.
 //
 // An ASN.1 BIT STRING can be written to a BitString.
 //
-// An ASN.1 OCTET STRING can be written to a []byte.
+// An ASN.1 OCTET STRING can be written to an OctetString or any other
+// slice of bytes.
 //
 // An ASN.1 OBJECT IDENTIFIER can be written to an
 // ObjectIdentifier.
 //
+// An ASN.1 RELATIVE-OID can be written to a RelativeOID.
+//
 // An ASN.1 ENUMERATED can be written to an Enumerated.
 //
+// An ASN.1 REAL can be written to a float32 or float64.
+//
+// An ASN.1 NULL can be written to a Null.
+//
+// An ASN.1 NumericString, PrintableString, IA5String, VisibleString,
+// UTF8String, BMPString or UniversalString can be written to a string with the
+// corresponding tag. Their size constraints count characters rather than
+// bytes. An untagged string is encoded as an OCTET STRING.
+//
+// An ASN.1 GeneralizedTime or UTCTime can be written to a time.Time. Marshal
+// uses the canonical UTC form. A GeneralizedTime without a time zone is
+// decoded as UTC.
+//
+// An ASN.1 TIME, DATE, TIME-OF-DAY, DATE-TIME or DURATION can be written to a
+// Time, Date, TimeOfDay, DateTime or Duration.
+//
+// Unknown extensions of a SEQUENCE or CHOICE are written to a RawExtensions
+// field, and an open type whose reference value matches no field, or whose reference
+// field is a global OBJECT IDENTIFIER, is written to an UnknownOpenType field, if the
+// struct has one. Marshal re-encodes them verbatim.
+//
+// An open type can be written to an interface, with the Go type which is registered
+// for its reference value by RegisterOpenType.
+//
 // Any of the above ASN.1 values can be written to an interface{}.
 // The value stored in the interface has the corresponding Go type.
 // For integers, that type is int64.
 //
 // An ASN.1 SEQUENCE OF x can be written
 // to a slice if an x can be written to the slice's element type.
+// The elements use the tag of the slice without its size constraint,
+// overridden by the elem: parts of the tag. For a slice of slices, such as
+// [][]T, elem:elem: parts apply to the innermost elements.
+//
+// An ASN.1 SET or SET OF is a struct or slice with the set tag. The components of a
+// SET are encoded in canonical tag order, by their tag option or else their
+// universal tag. Marshal sorts the elements of a SET OF by their encodings.
 //
 // An ASN.1 SEQUENCE can be written to a struct
 // if each of the elements in the sequence can be
 // written to the corresponding element in the struct.
 //
+// The fields of an anonymous embedded struct without a tag are components of the
+// enclosing SEQUENCE or SET, as COMPONENTS OF the embedded one. Its extension
+// additions are left out.
+//
 // The following tags on struct fields have special meaning to Unmarshal:
 //
 //		optional        	OPTIONAL tag in SEQUENCE
@@ -901,10 +1604,26 @@ func parseField(v reflect.Value, pd *perBitData, params fieldParameters) error {
 //		sizeUB              set the maximum value of value constraint
 //		valueLB		        set the minimum value of size constraint
 //		valueUB             set the maximum value of value constraint
-//		default             sets the default value
+//		default:x           sets the DEFAULT value of a BOOLEAN, INTEGER, ENUMERATED or string field (an error otherwise)
 //		openType            specifies the open Type
-//	 referenceFieldName	the string of the reference field for this type (only if openType used)
+//		extAddition         specifies that the field (a pointer, slice or interface) is an extension addition
+//		extGroup            sets the extension addition group ([[ ... ]]) which the field belongs to
+//		numeric             specifies that the string is a NumericString
+//		printable           specifies that the string is a PrintableString
+//		ia5                 specifies that the string is an IA5String
+//		visible             specifies that the string is a VisibleString
+//		utf8                specifies that the string is a UTF8String
+//		bmp                 specifies that the string is a BMPString
+//		universal           specifies that the string is a UniversalString
+//		generalized         specifies that the time.Time is a GeneralizedTime (default)
+//		utc                 specifies that the time.Time is a UTCTime
+//		from:'...'          sets the permitted alphabet of the string ('' for a single quote)
+//		set                 specifies that the struct is a SET, or the slice is a SET OF
+//		tag:[class:]N       sets the tag (class universal, application, context or private) of a SET component
+//		elem:x              applies the tag part x to the elements of a SEQUENCE OF or SET OF
+//	 referenceFieldName	the reference field for this type, or a path such as @..id (only if openType used)
 //	 referenceFieldValue	the corresponding value of the reference field for this type (only if openType used)
+//		objectSet:x         sets the object set of an interface open type (its package-qualified type name by default)
 //
 // Other ASN.1 types are not supported; if it encounters them,
 // Unmarshal returns a parse error.
@@ -916,6 +1635,6 @@ func Unmarshal(b []byte, value interface{}) error {
 // top-level element. The form of the params is the same as the field tags.
 func UnmarshalWithParams(b []byte, value interface{}, params string) error {
 	v := reflect.ValueOf(value).Elem()
-	pd := &perBitData{b, 0, 0}
+	pd := &perBitData{b, 0, 0, nil}
 	return parseField(v, pd, parseFieldParameters(params))
 }
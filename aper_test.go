@@ -6,9 +6,12 @@ package aper
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/omec-project/aper/logger"
 	"github.com/stretchr/testify/assert"
@@ -27,6 +30,26 @@ type testData struct {
 	Out interface{}
 }
 
+// testRoundTrip checks that each test.in is decoded to test.Out, and that test.Out
+// is encoded back to test.in.
+func testRoundTrip(t *testing.T, data []testData) {
+	t.Helper()
+	for i, test := range data {
+		perTestTrace(1, fmt.Sprintf("[TEST %d]\n", i+1))
+		out := reflect.New(reflect.TypeOf(test.Out))
+		err := Unmarshal(test.in, out.Interface())
+		perTestTrace(2, fmt.Sprintf("	in : %0x", test.in))
+		perTestTrace(2, fmt.Sprintf("	out : %v", out.Elem()))
+		perTestTrace(2, fmt.Sprintf("	exp : %v", reflect.ValueOf(test.Out)))
+		assert.NoError(t, err, "TEST %d", i+1)
+		assert.Equal(t, test.Out, out.Elem().Interface(), "TEST %d", i+1)
+
+		encoded, err := Marshal(test.Out)
+		assert.NoError(t, err, "TEST %d", i+1)
+		assert.Equal(t, test.in, encoded, "TEST %d", i+1)
+	}
+}
+
 // TEST BIT STRING
 
 // BitStringTest1 is for no constraint
@@ -582,6 +605,56 @@ func TestStructInteger(t *testing.T) {
 	}
 }
 
+// semi-constrained values are encoded in the fewest octets of their offset from valueLB
+type semiConstrainedIntTest1 struct {
+	Value int64 `aper:"valueLB:0"`
+}
+
+type semiConstrainedIntTest2 struct {
+	Value int64 `aper:"valueLB:1000"`
+}
+
+type semiConstrainedIntTest3 struct {
+	Value int64 `aper:"valueLB:-5"`
+}
+
+var semiConstrainedIntegerTestData = []testData{
+	{[]byte{0x01, 0x7F}, semiConstrainedIntTest1{127}},
+	{[]byte{0x01, 0xC8}, semiConstrainedIntTest1{200}},
+	{[]byte{0x02, 0x01, 0x00}, semiConstrainedIntTest1{256}},
+	{[]byte{0x01, 0x00}, semiConstrainedIntTest2{1000}},
+	{[]byte{0x01, 0x64}, semiConstrainedIntTest2{1100}},
+	{[]byte{0x02, 0x01, 0x00}, semiConstrainedIntTest2{1256}},
+	{[]byte{0x01, 0xFF}, semiConstrainedIntTest3{250}},
+}
+
+func TestSemiConstrainedInteger(t *testing.T) {
+	testRoundTrip(t, semiConstrainedIntegerTestData)
+}
+
+// the length of a value of a range above 64K is a constrained whole number of 1 to
+// the number of octets of the range
+type largeRangeIntTest1 struct {
+	Value int64 `aper:"valueLB:0,valueUB:131071"`
+}
+
+type largeRangeIntTest2 struct {
+	Value int64 `aper:"valueLB:0,valueUB:4294967295"`
+}
+
+var largeRangeIntegerTestData = []testData{
+	{[]byte{0x00, 0x05}, largeRangeIntTest1{5}},
+	{[]byte{0x40, 0x01, 0x00}, largeRangeIntTest1{256}},
+	{[]byte{0x80, 0x01, 0x00, 0x00}, largeRangeIntTest1{65536}},
+	{[]byte{0x80, 0x01, 0xFF, 0xFF}, largeRangeIntTest1{131071}},
+	{[]byte{0x80, 0x01, 0x00, 0x00}, largeRangeIntTest2{65536}},
+	{[]byte{0xC0, 0xFF, 0xFF, 0xFF, 0xFF}, largeRangeIntTest2{4294967295}},
+}
+
+func TestLargeRangeInteger(t *testing.T) {
+	testRoundTrip(t, largeRangeIntegerTestData)
+}
+
 // TEST ENUMERATED
 
 // value is unconstraint
@@ -835,6 +908,39 @@ func TestChoice(t *testing.T) {
 	}
 }
 
+type choiceExtTest1 struct {
+	Choice choiceExtStruct `aper:"valueExt,valueLB:0,valueUB:1"`
+}
+
+type choiceExtStruct struct {
+	Present int
+	Int1    int64 `aper:"valueLB:0,valueUB:255"`
+	Bool    bool
+	Int2    int64 `aper:"valueLB:0,valueUB:7"`
+}
+
+var choiceExtTest1Data = []choiceExtTest1{
+	{choiceExtStruct{1, 5, false, 0}},
+	{choiceExtStruct{2, 0, true, 0}},
+	{choiceExtStruct{3, 0, false, 3}},
+}
+
+var choiceExtTestData = []testData{
+	{[]byte{0x00, 0x05}, choiceExtTest1Data[0]},
+	{[]byte{0x60}, choiceExtTest1Data[1]},
+	{[]byte{0x80, 0x01, 0x60}, choiceExtTest1Data[2]},
+}
+
+func TestChoiceExtension(t *testing.T) {
+	testRoundTrip(t, choiceExtTestData)
+
+	// extension alternatives unknown to the struct are skipped
+	var x choiceExtTest1
+	err := Unmarshal([]byte{0x81, 0x01, 0xFF}, &x)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, x.Choice.Present)
+}
+
 // TEST PrintableString
 type printableStringStructTest1 struct {
 	PrintableString1 string `aper:"sizeExt,sizeLB:1,sizeUB:1"`
@@ -943,6 +1049,1210 @@ func TestOpenType(t *testing.T) {
 	assert.Equal(t, 0, x.Value.Present)
 }
 
+// OPEN TYPE REFERENCE TEST
+type referenceTest1 struct {
+	Kind  Enumerated           `aper:"valueLB:0,valueUB:3"`
+	Value referenceValueStruct `aper:"openType,referenceFieldName:Kind"`
+}
+
+type referenceTest2 struct {
+	Code  uint8                `aper:"valueLB:0,valueUB:255"`
+	Value referenceValueStruct `aper:"openType,referenceFieldName:@.Code"`
+}
+
+type referenceTest3 struct {
+	ProcedureCode int64 `aper:"valueLB:0,valueUB:255"`
+	Header        referenceHeader
+}
+
+type referenceHeader struct {
+	Flag  bool
+	Value referenceValueStruct `aper:"openType,referenceFieldName:@..ProcedureCode"`
+}
+
+type referenceTest4 struct {
+	ProcedureCode int64 `aper:"valueLB:0,valueUB:255"`
+	Header        referenceHeader2
+}
+
+type referenceHeader2 struct {
+	Flag  bool
+	Value referenceValueStruct `aper:"openType,referenceFieldName:@ProcedureCode"`
+}
+
+type referenceTest5 struct {
+	Header        referenceHeader3
+	ProcedureCode int64 `aper:"valueLB:0,valueUB:255"`
+}
+
+type referenceHeader3 struct {
+	Value referenceValueStruct `aper:"openType,referenceFieldName:@..ProcedureCode"`
+}
+
+type referenceTest6 struct {
+	ProcedureCode int64 `aper:"valueLB:0,valueUB:255"`
+	Header        referenceHeader4
+}
+
+type referenceHeader4 struct {
+	Value referenceValueStruct `aper:"openType,referenceFieldName:@...ProcedureCode"`
+}
+
+type referenceValueStruct struct {
+	Present int
+	Int     int64 `aper:"valueLB:0,valueUB:255,referenceFieldValue:1"`
+	Bool    bool  `aper:"referenceFieldValue:2"`
+}
+
+var referenceTestData = []testData{
+	{[]byte{0x40, 0x01, 0x05}, referenceTest1{1, referenceValueStruct{Present: 1, Int: 5}}},
+	{[]byte{0x02, 0x01, 0x80}, referenceTest2{2, referenceValueStruct{Present: 2, Bool: true}}},
+	{[]byte{0x01, 0x80, 0x01, 0x07}, referenceTest3{1, referenceHeader{true, referenceValueStruct{Present: 1, Int: 7}}}},
+	{[]byte{0x02, 0x00, 0x01, 0x80},
+		referenceTest4{2, referenceHeader2{false, referenceValueStruct{Present: 2, Bool: true}}}},
+}
+
+func TestOpenTypeReference(t *testing.T) {
+	testRoundTrip(t, referenceTestData)
+
+	// the reference must precede the open type in the same SEQUENCE
+	_, err := Marshal(struct {
+		Value referenceValueStruct `aper:"openType,referenceFieldName:Kind"`
+		Kind  Enumerated           `aper:"valueLB:0,valueUB:3"`
+	}{referenceValueStruct{Present: 1}, 1})
+	assert.Error(t, err)
+	// nor the component which contains the open type in an enclosing SEQUENCE
+	_, err = Marshal(referenceTest5{referenceHeader3{referenceValueStruct{Present: 2, Bool: true}}, 2})
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x01, 0x80, 0x02}, &referenceTest5{})
+	assert.Error(t, err)
+	// there is no SEQUENCE enclosing the outermost one
+	_, err = Marshal(referenceTest6{1, referenceHeader4{referenceValueStruct{Present: 1, Int: 7}}})
+	assert.Error(t, err)
+	_, err = Marshal(struct {
+		Code  uint8                `aper:"valueLB:0,valueUB:255"`
+		Value referenceValueStruct `aper:"openType,referenceFieldName:@..Code"`
+	}{1, referenceValueStruct{Present: 1}})
+	assert.Error(t, err)
+}
+
+// REGISTERED OPEN TYPE TEST
+type objectSetTest1 struct {
+	Id          int64       `aper:"valueLB:0,valueUB:65535"`
+	Criticality Enumerated  `aper:"valueLB:0,valueUB:2"`
+	Value       interface{} `aper:"openType,referenceFieldName:Id,objectSet:TEST-PROTOCOL-IES"`
+}
+
+type objectSetValue interface{}
+
+// objectSetValueName is the default object set of objectSetValue, which is its package-qualified name.
+const objectSetValueName = "github.com/omec-project/aper.objectSetValue"
+
+type objectSetTest2 struct {
+	Id    int64          `aper:"valueLB:0,valueUB:255"`
+	Value objectSetValue `aper:"openType,referenceFieldName:Id,optional"`
+}
+
+type objectSetStruct struct {
+	Flag bool
+	Code int64 `aper:"valueLB:0,valueUB:15"`
+}
+
+var objectSetTestData = []testData{
+	{[]byte{0x00, 0x0a, 0x40, 0x01, 0x05}, objectSetTest1{10, 1, int64(5)}},
+	{[]byte{0x00, 0x0b, 0x00, 0x01, 0x98}, objectSetTest1{11, 0, &objectSetStruct{true, 3}}},
+	{[]byte{0x00, 0x0c, 0x80, 0x02, 0xab, 0xcd}, objectSetTest1{12, 2, UnknownOpenType{0xab, 0xcd}}},
+	{[]byte{0x80, 0x01, 0x04, 0x60, 0x61, 0x62, 0x63}, objectSetTest2{1, "abc"}},
+	{[]byte{0x00, 0x02}, objectSetTest2{2, nil}},
+	{[]byte{0x80, 0x03, 0x01, 0x05}, objectSetTest2{3, UnknownOpenType{0x05}}},
+}
+
+func TestRegisteredOpenType(t *testing.T) {
+	assert.NoError(t, RegisterOpenType("TEST-PROTOCOL-IES", 10, int64(0), "valueLB:0,valueUB:255"))
+	assert.NoError(t, RegisterOpenType("TEST-PROTOCOL-IES", 11, (*objectSetStruct)(nil), ""))
+	assert.NoError(t, RegisterOpenType(objectSetValueName, 1, "", "printable,sizeLB:0,sizeUB:7"))
+	// the same type may be registered again, but not another one or other params
+	assert.NoError(t, RegisterOpenType("TEST-PROTOCOL-IES", 10, int64(0), "valueLB:0,valueUB:255"))
+	assert.Error(t, RegisterOpenType("TEST-PROTOCOL-IES", 10, "", ""))
+	assert.Error(t, RegisterOpenType("TEST-PROTOCOL-IES", 10, int64(0), "valueLB:0,valueUB:65535"))
+	// an interface of the same name in another package has another object set
+	assert.NoError(t, RegisterOpenType("objectSetValue", 3, int64(0), "valueLB:0,valueUB:255"))
+	assert.Error(t, RegisterOpenType("TEST-PROTOCOL-IES", 13, nil, ""))
+	assert.Error(t, RegisterOpenType("", 13, int64(0), ""))
+
+	testRoundTrip(t, objectSetTestData)
+
+	// the value must have the type registered for the reference value
+	_, err := Marshal(objectSetTest1{10, 0, int32(5)})
+	assert.Error(t, err)
+	_, err = Marshal(objectSetTest1{13, 0, int64(5)})
+	assert.Error(t, err)
+	// an interface{} without an objectSet tag has no object set
+	_, err = Marshal(struct {
+		Id    int64       `aper:"valueLB:0,valueUB:255"`
+		Value interface{} `aper:"openType,referenceFieldName:Id"`
+	}{1, int64(5)})
+	assert.Error(t, err)
+}
+
+// COMPONENTS OF TEST
+type componentsOfCommon struct {
+	Id   int64       `aper:"valueLB:0,valueUB:255"`
+	Tags OctetString `aper:"sizeLB:1,sizeUB:1,optional"`
+	Ext  *int64      `aper:"extAddition,valueLB:0,valueUB:7"`
+}
+
+type componentsOfTest1 struct {
+	Code OctetString `aper:"sizeLB:1,sizeUB:1,optional"`
+	componentsOfCommon
+	Last bool
+}
+
+type ComponentsOfHeader struct {
+	Id int64 `aper:"valueLB:0,valueUB:255"`
+}
+
+type componentsOfTest2 struct {
+	ComponentsOfHeader
+	Value referenceValueStruct `aper:"openType,referenceFieldName:Id"`
+}
+
+type componentsOfTest3 struct {
+	ComponentsOfHeader `aper:"valueExt"`
+	Last               bool
+}
+
+var componentsOfTestData = []testData{
+	{[]byte{0xea, 0x80, 0x05, 0xbb, 0x80},
+		componentsOfTest1{OctetString{0xaa}, componentsOfCommon{Id: 5, Tags: OctetString{0xbb}}, true}},
+	{[]byte{0x00, 0x05, 0x80}, componentsOfTest1{nil, componentsOfCommon{Id: 5}, true}},
+	{[]byte{0x01, 0x01, 0x09}, componentsOfTest2{ComponentsOfHeader{1}, referenceValueStruct{Present: 1, Int: 9}}},
+	// a tagged embedded struct is a SEQUENCE component of its own
+	{[]byte{0x00, 0x07, 0x80}, componentsOfTest3{ComponentsOfHeader{7}, true}},
+}
+
+func TestComponentsOf(t *testing.T) {
+	testRoundTrip(t, componentsOfTestData)
+}
+
+// PROTOCOL CONTAINER TEST
+func TestProtocolContainer(t *testing.T) {
+	assert.NoError(t, RegisterOpenType(objectSetValueName, 1, "", "printable,sizeLB:0,sizeUB:7"))
+	local := int64(2)
+	global, err := ParseObjectIdentifier("1.3.6.1")
+	assert.NoError(t, err)
+	protocolContainerTestData := []testData{
+		{
+			[]byte{0x00, 0x02, 0x00, 0x01, 0x00, 0x01, 0x05, 0x00, 0x02, 0x40, 0x01, 0x80},
+			ProtocolIEContainer[referenceValueStruct]{[]ProtocolIEField[referenceValueStruct]{
+				{1, CriticalityReject, referenceValueStruct{Present: 1, Int: 5}},
+				{2, CriticalityIgnore, referenceValueStruct{Present: 2, Bool: true}},
+			}},
+		},
+		{[]byte{0x00, 0x00}, ProtocolIEContainer[referenceValueStruct]{[]ProtocolIEField[referenceValueStruct]{}}},
+		{
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x40, 0x04, 0x60, 0x61, 0x62, 0x63},
+			ProtocolExtensionContainer[objectSetValue]{[]ProtocolExtensionField[objectSetValue]{
+				{1, CriticalityIgnore, "abc"},
+			}},
+		},
+		{
+			[]byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x80, 0x01, 0x00},
+			PrivateIEContainer[referenceValueStruct]{[]PrivateIEField[referenceValueStruct]{
+				{PrivateIEID{Present: PrivateIEIDPresentLocal, Local: &local}, CriticalityNotify,
+					referenceValueStruct{Present: 2, Bool: false}},
+			}},
+		},
+		{
+			[]byte{0x00, 0x00, 0x80, 0x03, 0x2b, 0x06, 0x01, 0x00, 0x02, 0x12, 0x34},
+			PrivateIEContainer[objectSetValue]{[]PrivateIEField[objectSetValue]{
+				{PrivateIEID{Present: PrivateIEIDPresentGlobal, Global: &global}, CriticalityReject,
+					UnknownOpenType{0x12, 0x34}},
+			}},
+		},
+	}
+
+	testRoundTrip(t, protocolContainerTestData)
+
+	// a ProtocolExtensionContainer or PrivateIEContainer has at least one field
+	_, err = Marshal(ProtocolExtensionContainer[objectSetValue]{})
+	assert.Error(t, err)
+	// a global Id cannot select the value, which is skipped if it cannot be UnknownOpenType
+	var privateIEs PrivateIEContainer[referenceValueStruct]
+	assert.NoError(t, Unmarshal([]byte{0x00, 0x00, 0x80, 0x03, 0x2b, 0x06, 0x01, 0x00, 0x02, 0x12, 0x34}, &privateIEs))
+	assert.Equal(t, []PrivateIEField[referenceValueStruct]{
+		{PrivateIEID{Present: PrivateIEIDPresentGlobal, Global: &global}, CriticalityReject, referenceValueStruct{}},
+	}, privateIEs.List)
+	_, err = Marshal(PrivateIEContainer[referenceValueStruct]{[]PrivateIEField[referenceValueStruct]{
+		{PrivateIEID{Present: PrivateIEIDPresentGlobal, Global: &global}, CriticalityReject,
+			referenceValueStruct{Present: 1, Int: 5}},
+	}})
+	assert.Error(t, err)
+	_, err = Marshal(PrivateIEContainer[objectSetValue]{[]PrivateIEField[objectSetValue]{
+		{PrivateIEID{Present: PrivateIEIDPresentGlobal, Global: &global}, CriticalityReject, "abc"},
+	}})
+	assert.Error(t, err)
+}
+
+// TEST SEQUENCE extension additions
+type seqExtTest1 struct {
+	Value seqExtStruct `aper:"valueExt"`
+}
+
+type seqExtStruct struct {
+	Int1 int64  `aper:"valueLB:0,valueUB:255"`
+	Int2 *int64 `aper:"valueLB:0,valueUB:255,extAddition"`
+	Bool *bool  `aper:"extAddition"`
+}
+
+var (
+	seqExtInt  = int64(7)
+	seqExtBool = true
+)
+
+var seqExtTest1Data = []seqExtTest1{
+	{seqExtStruct{5, nil, nil}},
+	{seqExtStruct{5, &seqExtInt, nil}},
+	{seqExtStruct{5, &seqExtInt, &seqExtBool}},
+}
+
+var seqExtTestData = []testData{
+	{[]byte{0x00, 0x05}, seqExtTest1Data[0]},
+	{[]byte{0x80, 0x05, 0x03, 0x00, 0x01, 0x07}, seqExtTest1Data[1]},
+	{[]byte{0x80, 0x05, 0x03, 0x80, 0x01, 0x07, 0x01, 0x80}, seqExtTest1Data[2]},
+}
+
+func TestSequenceExtension(t *testing.T) {
+	testRoundTrip(t, seqExtTestData)
+
+	// extension additions unknown to the struct are skipped
+	var x seqExtTest1
+	err := Unmarshal([]byte{0x80, 0x05, 0x05, 0xC0, 0x01, 0x07, 0x01, 0x80, 0x02, 0xAB, 0xCD}, &x)
+	assert.NoError(t, err)
+	assert.Equal(t, seqExtTest1Data[2], x)
+
+	// an extension addition which cannot be nil would always be present
+	type seqExtTest2 struct {
+		Int1 int64 `aper:"valueLB:0,valueUB:255"`
+		Int2 int64 `aper:"valueLB:0,valueUB:255,extAddition"`
+	}
+	var y struct {
+		Value seqExtTest2 `aper:"valueExt"`
+	}
+	_, err = Marshal(y)
+	assert.Error(t, err)
+	assert.Error(t, Unmarshal([]byte{0x00, 0x05}, &y))
+}
+
+// TEST SEQUENCE extension addition groups
+type seqExtGroupTest1 struct {
+	Value seqExtGroupStruct `aper:"valueExt"`
+}
+
+type seqExtGroupStruct struct {
+	Int1 int64  `aper:"valueLB:0,valueUB:255"`
+	Int2 *int64 `aper:"valueLB:0,valueUB:255,extGroup:1"`
+	Bool *bool  `aper:"optional,extGroup:1"`
+	Int3 *int64 `aper:"valueLB:0,valueUB:7,extAddition"`
+}
+
+var seqExtGroupInt = int64(3)
+
+var seqExtGroupTest1Data = []seqExtGroupTest1{
+	{seqExtGroupStruct{5, nil, nil, nil}},
+	{seqExtGroupStruct{5, &seqExtInt, nil, nil}},
+	{seqExtGroupStruct{5, &seqExtInt, &seqExtBool, &seqExtGroupInt}},
+}
+
+var seqExtGroupTestData = []testData{
+	{[]byte{0x00, 0x05}, seqExtGroupTest1Data[0]},
+	{[]byte{0x80, 0x05, 0x03, 0x00, 0x02, 0x00, 0x07}, seqExtGroupTest1Data[1]},
+	{[]byte{0x80, 0x05, 0x03, 0x80, 0x03, 0x80, 0x07, 0x80, 0x01, 0x60}, seqExtGroupTest1Data[2]},
+}
+
+func TestSequenceExtensionGroup(t *testing.T) {
+	testRoundTrip(t, seqExtGroupTestData)
+}
+
+// TEST unknown extensions and open types
+type rawExtTest1 struct {
+	Value rawExtSeqStruct `aper:"valueExt"`
+}
+
+type rawExtSeqStruct struct {
+	Int1 int64  `aper:"valueLB:0,valueUB:255"`
+	Int2 *int64 `aper:"valueLB:0,valueUB:255,extAddition"`
+	Bool *bool  `aper:"extAddition"`
+	Raw  RawExtensions
+}
+
+type rawExtTest2 struct {
+	Choice rawExtChoiceStruct `aper:"valueExt,valueLB:0,valueUB:1"`
+}
+
+type rawExtChoiceStruct struct {
+	Present int
+	Int1    int64 `aper:"valueLB:0,valueUB:255"`
+	Bool    bool
+	Int2    int64 `aper:"valueLB:0,valueUB:7"`
+	Raw     RawExtensions
+}
+
+type rawExtTest3 struct {
+	ID    int64             `aper:"valueLB:0,valueUB:255"`
+	Value rawOpenTypeStruct `aper:"openType,referenceFieldName:ID"`
+}
+
+type rawOpenTypeStruct struct {
+	Present int
+	List1   []intTest1 `aper:"sizeLB:0,sizeUB:3,referenceFieldValue:2"`
+	Unknown UnknownOpenType
+}
+
+var rawExtTestData = []testData{
+	{
+		[]byte{0x80, 0x05, 0x05, 0xC0, 0x01, 0x07, 0x01, 0x80, 0x02, 0xAB, 0xCD},
+		rawExtTest1{rawExtSeqStruct{5, &seqExtInt, &seqExtBool, RawExtensions{{2, []byte{0xAB, 0xCD}}}}},
+	},
+	{
+		[]byte{0x80, 0x05, 0x07, 0x20, 0x01, 0x07, 0x01, 0xFF},
+		rawExtTest1{rawExtSeqStruct{5, &seqExtInt, nil, RawExtensions{{3, []byte{0xFF}}}}},
+	},
+	{[]byte{0x81, 0x01, 0xFF}, rawExtTest2{rawExtChoiceStruct{4, 0, false, 0, RawExtensions{{1, []byte{0xFF}}}}}},
+	{[]byte{0x80, 0x01, 0x60}, rawExtTest2{rawExtChoiceStruct{3, 0, false, 3, nil}}},
+	{
+		[]byte{0x11, 0x08, 0x06, 0x88, 0xFE, 0x06, 0xEC, 0x00, 0x05, 0xD8},
+		rawExtTest3{17, rawOpenTypeStruct{2, nil, UnknownOpenType{0x06, 0x88, 0xFE, 0x06, 0xEC, 0x00, 0x05, 0xD8}}},
+	},
+}
+
+func TestRawExtensions(t *testing.T) {
+	testRoundTrip(t, rawExtTestData)
+
+	// raw extensions need the extension bit of an extensible SEQUENCE
+	_, err := Marshal(struct {
+		Int1 int64 `aper:"valueLB:0,valueUB:255"`
+		Raw  RawExtensions
+	}{5, RawExtensions{{0, []byte{0x01}}}})
+	assert.Error(t, err)
+}
+
+// TEST OBJECT IDENTIFIER
+type oidTest1 struct {
+	OID ObjectIdentifier
+}
+
+var oidTestData = []testData{
+	{
+		[]byte{0x08, 0x2B, 0x06, 0x01, 0x04, 0x01, 0x83, 0x9F, 0x1C},
+		oidTest1{ObjectIdentifier{0x2B, 0x06, 0x01, 0x04, 0x01, 0x83, 0x9F, 0x1C}},
+	},
+	{[]byte{0x03, 0x88, 0x37, 0x03}, oidTest1{ObjectIdentifier{0x88, 0x37, 0x03}}},
+}
+
+func TestObjectIdentifier(t *testing.T) {
+	testRoundTrip(t, oidTestData)
+
+	oid, err := ParseObjectIdentifier("1.3.6.1.4.1.53148")
+	assert.NoError(t, err)
+	assert.Equal(t, oidTestData[0].Out.(oidTest1).OID, oid)
+	assert.Equal(t, "1.3.6.1.4.1.53148", oid.String())
+
+	oid, err = NewObjectIdentifier([]uint64{2, 999, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, oidTestData[1].Out.(oidTest1).OID, oid)
+	arcs, err := oid.Arcs()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 999, 3}, arcs)
+
+	_, err = NewObjectIdentifier([]uint64{1, 40})
+	assert.Error(t, err)
+	_, err = ParseObjectIdentifier("1.3.x")
+	assert.Error(t, err)
+	_, err = ObjectIdentifier{0x2B, 0x86}.Arcs()
+	assert.Error(t, err)
+	_, err = ObjectIdentifier{0x2B, 0x80, 0x01}.Arcs()
+	assert.Error(t, err)
+}
+
+// TEST RELATIVE-OID
+type relativeOIDTest1 struct {
+	ROID RelativeOID
+}
+
+var relativeOIDTestData = []testData{
+	{[]byte{0x04, 0xC2, 0x7B, 0x03, 0x02}, relativeOIDTest1{RelativeOID{0xC2, 0x7B, 0x03, 0x02}}},
+	{[]byte{0x01, 0x00}, relativeOIDTest1{RelativeOID{0x00}}},
+}
+
+func TestRelativeOID(t *testing.T) {
+	testRoundTrip(t, relativeOIDTestData)
+
+	roid, err := ParseRelativeOID("8571.3.2")
+	assert.NoError(t, err)
+	assert.Equal(t, relativeOIDTestData[0].Out.(relativeOIDTest1).ROID, roid)
+	assert.Equal(t, "8571.3.2", roid.String())
+
+	roid, err = NewRelativeOID([]uint64{0})
+	assert.NoError(t, err)
+	arcs, err := roid.Arcs()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0}, arcs)
+
+	_, err = NewRelativeOID(nil)
+	assert.Error(t, err)
+	_, err = RelativeOID{0xC2}.Arcs()
+	assert.Error(t, err)
+}
+
+// TEST REAL
+type realTest1 struct {
+	Value float64
+}
+
+type realTest2 struct {
+	Value float32
+}
+
+var realTestData = []testData{
+	{[]byte{0x03, 0x80, 0x00, 0x01}, realTest1{1}},
+	{[]byte{0x03, 0xC0, 0xFF, 0x05}, realTest1{-2.5}},
+	{[]byte{0x04, 0x81, 0xFB, 0xCE, 0x01}, realTest1{math.SmallestNonzeroFloat64}},
+	{[]byte{0x00}, realTest1{0}},
+	{[]byte{0x01, 0x43}, realTest1{math.Copysign(0, -1)}},
+	{[]byte{0x01, 0x40}, realTest1{math.Inf(1)}},
+	{[]byte{0x01, 0x41}, realTest1{math.Inf(-1)}},
+	{[]byte{0x03, 0x80, 0x00, 0x01}, realTest2{1}},
+}
+
+func TestReal(t *testing.T) {
+	testRoundTrip(t, realTestData)
+
+	// assert.Equal does not tell -0 from 0
+	var negativeZero realTest1
+	assert.NoError(t, Unmarshal([]byte{0x01, 0x43}, &negativeZero))
+	assert.True(t, math.Signbit(negativeZero.Value))
+
+	for _, value := range []float64{0.1, -123456.789, math.MaxFloat64, 1e-310} {
+		encoded, err := Marshal(realTest1{value})
+		assert.NoError(t, err)
+		var x realTest1
+		assert.NoError(t, Unmarshal(encoded, &x))
+		assert.Equal(t, value, x.Value)
+	}
+
+	var x realTest1
+	assert.NoError(t, Unmarshal([]byte{0x01, 0x42}, &x))
+	assert.True(t, math.IsNaN(x.Value))
+	// decimal NR3 form
+	assert.NoError(t, Unmarshal([]byte{0x07, 0x03, '1', '.', '5', 'E', '+', '3'}, &x))
+	assert.Equal(t, 1500.0, x.Value)
+	// binary form in base 16
+	assert.NoError(t, Unmarshal([]byte{0x03, 0xA0, 0x01, 0x01}, &x))
+	assert.Equal(t, 16.0, x.Value)
+
+	var y realTest2
+	assert.Error(t, Unmarshal([]byte{0x04, 0x81, 0x03, 0xE8, 0x01}, &y))
+}
+
+// TEST NULL
+type nullTest1 struct {
+	Choice nullChoiceStruct `aper:"valueExt,valueLB:0,valueUB:1"`
+}
+
+type nullChoiceStruct struct {
+	Present   int
+	CGI       int64 `aper:"valueLB:0,valueUB:255"`
+	CGIAbsent Null
+	Unknown   Null
+}
+
+type nullTest2 struct {
+	Int1 int64 `aper:"valueLB:0,valueUB:255"`
+	Null Null
+	Opt  *Null `aper:"optional"`
+}
+
+type nullTest3 struct {
+	Int1  int64 `aper:"valueLB:0,valueUB:255"`
+	Nulls nullSequence
+}
+
+// nullSequence is encoded in no bits
+type nullSequence struct {
+	Null  Null
+	Fixed int64 `aper:"valueLB:3,valueUB:3"`
+}
+
+type nullTest4 struct {
+	List []Null
+}
+
+var nullTestData = []testData{
+	{[]byte{0x40}, nullTest1{nullChoiceStruct{Present: 2}}},
+	{[]byte{0x80, 0x01, 0x00}, nullTest1{nullChoiceStruct{Present: 3}}},
+	{[]byte{0x00, 0x05}, nullTest2{5, Null{}, nil}},
+	{[]byte{0x80, 0x05}, nullTest2{5, Null{}, &Null{}}},
+	{[]byte{0x05}, nullTest3{5, nullSequence{Fixed: 3}}},
+	{[]byte{0x03}, nullTest4{[]Null{{}, {}, {}}}},
+}
+
+func TestNull(t *testing.T) {
+	testRoundTrip(t, nullTestData)
+
+	// each fragment of 64K NULL elements takes one octet
+	var list nullTest4
+	assert.NoError(t, Unmarshal([]byte{0xC4, 0x00}, &list))
+	assert.Len(t, list.List, 65536)
+	assert.Error(t, Unmarshal([]byte{0xC4, 0xC4, 0x00}, &list))
+}
+
+// CHARACTER STRING TEST
+type characterStringTest1 struct {
+	Value string `aper:"numeric"`
+}
+
+type characterStringTest2 struct {
+	Value string `aper:"printable,sizeLB:1,sizeUB:150"`
+}
+
+type characterStringTest3 struct {
+	Flag  bool
+	Value string `aper:"ia5,sizeLB:2,sizeUB:2"`
+}
+
+type characterStringTest4 struct {
+	Value string `aper:"visible,from:'0123456789ABCDEF',sizeLB:1,sizeUB:8"`
+}
+
+type characterStringTest5 struct {
+	Value string `aper:"printable,from:'A,'''"`
+}
+
+type characterStringTest6 struct {
+	Value string `aper:"visible,sizeExt,sizeLB:1,sizeUB:2"`
+}
+
+type characterStringTest7 struct {
+	Value string `aper:"bmp"`
+}
+
+type characterStringTest8 struct {
+	Value string `aper:"universal,sizeLB:1,sizeUB:4"`
+}
+
+type characterStringTest9 struct {
+	Value string `aper:"utf8,sizeLB:1,sizeUB:1"`
+}
+
+var characterStringTestData = []testData{
+	{[]byte{0x03, 0x23, 0x40}, characterStringTest1{"123"}},
+	{[]byte{0x02, 0x41, 0x4d, 0x46}, characterStringTest2{"AMF"}},
+	{[]byte{0xb0, 0xb1, 0x00}, characterStringTest3{true, "ab"}},
+	{[]byte{0x20, 0x1f}, characterStringTest4{"1F"}},
+	{[]byte{0x03, 0x84}, characterStringTest5{"A',"}},
+	{[]byte{0x80, 0x03, 0x61, 0x62, 0x63}, characterStringTest6{"abc"}},
+	{[]byte{0x02, 0x00, 0x61, 0x00, 0xe9}, characterStringTest7{"aé"}},
+	{[]byte{0x00, 0x00, 0x01, 0xf6, 0x00}, characterStringTest8{"😀"}},
+	{[]byte{0x02, 0xc3, 0xa9}, characterStringTest9{"é"}},
+}
+
+func TestCharacterString(t *testing.T) {
+	testRoundTrip(t, characterStringTestData)
+
+	_, err := Marshal(characterStringTest2{"AMF@1"})
+	assert.Error(t, err)
+	_, err = Marshal(characterStringTest4{"1f"})
+	assert.Error(t, err)
+	_, err = Marshal(characterStringTest7{"😀"})
+	assert.Error(t, err)
+	_, err = Marshal(characterStringTest9{"ab"})
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x01, 0xff}, &characterStringTest9{})
+	assert.Error(t, err)
+	// invalid characters are not replaced with U+FFFD
+	_, err = Marshal(characterStringTest7{"a\xff"})
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x01, 0xd8, 0x00}, &characterStringTest7{})
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x00, 0x00, 0x11, 0x00, 0x00}, &characterStringTest8{})
+	assert.Error(t, err)
+}
+
+// TIME TEST
+type timeTest1 struct {
+	Value time.Time `aper:"generalized"`
+}
+
+type timeTest2 struct {
+	Value time.Time `aper:"utc"`
+}
+
+var timeTestData = []testData{
+	{append([]byte{0x11}, "20230506070809.5Z"...),
+		timeTest1{time.Date(2023, 5, 6, 7, 8, 9, 500000000, time.UTC)}},
+	{append([]byte{0x0f}, "20230506070809Z"...), timeTest1{time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)}},
+	{append([]byte{0x0d}, "991231235959Z"...), timeTest2{time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)}},
+	{append([]byte{0x0d}, "490101000000Z"...), timeTest2{time.Date(2049, 1, 1, 0, 0, 0, 0, time.UTC)}},
+}
+
+func TestTime(t *testing.T) {
+	testRoundTrip(t, timeTestData)
+
+	// a local time without a time zone is decoded as UTC, whatever the zone of the host
+	var local timeTest1
+	assert.NoError(t, Unmarshal(append([]byte{0x0e}, "20230506070809"...), &local))
+	assert.Equal(t, time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC), local.Value)
+
+	// other time zones are decoded to the same instant and encoded in UTC
+	var generalized timeTest1
+	err := Unmarshal(append([]byte{0x15}, "20230506090809.5+0200"...), &generalized)
+	assert.NoError(t, err)
+	assert.True(t, timeTestData[0].Out.(timeTest1).Value.Equal(generalized.Value))
+	encoded, err := Marshal(generalized)
+	assert.NoError(t, err)
+	assert.Equal(t, timeTestData[0].in, encoded)
+
+	var utc timeTest2
+	err = Unmarshal(append([]byte{0x0f}, "9912311859-0500"...), &utc)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(1999, 12, 31, 23, 59, 0, 0, time.UTC).Equal(utc.Value))
+
+	_, err = Marshal(timeTest2{time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.Error(t, err)
+	err = Unmarshal(append([]byte{0x0a}, "9912312359"...), &utc)
+	assert.Error(t, err)
+}
+
+// TIME TYPES TEST
+type timeTypesTest1 struct {
+	Value Date
+}
+
+type timeTypesTest2 struct {
+	Value TimeOfDay
+}
+
+type timeTypesTest3 struct {
+	Value DateTime
+}
+
+type timeTypesTest4 struct {
+	Value Duration
+}
+
+type timeTypesTest5 struct {
+	Value Time
+}
+
+var (
+	durationHours   int64 = 2
+	durationMinutes int64 = 30
+	durationDays    int64 = 100
+	durationSeconds int64 = 30
+)
+
+var timeTypesTestData = []testData{
+	{[]byte{0x39, 0x5c}, timeTypesTest1{Date{2019, 6, 15}}},
+	{[]byte{0x40, 0x02, 0x00, 0x00}, timeTypesTest1{Date{2023, 1, 1}}},
+	{[]byte{0xc0, 0x02, 0x06, 0xa4, 0xbf, 0x00}, timeTypesTest1{Date{1700, 12, 31}}},
+	{[]byte{0xbf, 0x7e, 0x00}, timeTypesTest2{TimeOfDay{23, 59, 60}}},
+	{[]byte{0x39, 0x5c, 0x00, 0x00}, timeTypesTest3{DateTime{Date{2019, 6, 15}, TimeOfDay{}}}},
+	{[]byte{0x0c, 0x08, 0xf0}, timeTypesTest4{Duration{Hours: &durationHours, Minutes: &durationMinutes}}},
+	{
+		[]byte{0x11, 0x80, 0x01, 0x64, 0x00, 0x00, 0x05},
+		timeTypesTest4{Duration{Days: &durationDays, FractionalPart: &DurationFraction{1, 5}}},
+	},
+	// PT30.250S, with number-of-digits in INTEGER (1..3, ...) and fractional-value in INTEGER (0..999, ...)
+	{
+		[]byte{0x03, 0x3c, 0x80, 0x00, 0xfa},
+		timeTypesTest4{Duration{Seconds: &durationSeconds, FractionalPart: &DurationFraction{3, 250}}},
+	},
+	// 4 digits are outside the extension root
+	{
+		[]byte{0x01, 0x80, 0x01, 0x04, 0x00, 0x00, 0x05},
+		timeTypesTest4{Duration{FractionalPart: &DurationFraction{4, 5}}},
+	},
+	{append([]byte{0x14}, "2023-05-06T07:08:09Z"...), timeTypesTest5{"2023-05-06T07:08:09Z"}},
+}
+
+func TestTimeTypes(t *testing.T) {
+	testRoundTrip(t, timeTypesTestData)
+
+	_, err := Marshal(timeTypesTest1{Date{2019, 13, 1}})
+	assert.Error(t, err)
+	// the year 2000 must use the near-past alternative
+	err = Unmarshal([]byte{0xc0, 0x02, 0x07, 0xd0, 0x00, 0x00}, &timeTypesTest1{})
+	assert.Error(t, err)
+}
+
+// DEFAULT TEST
+type defaultTest1 struct {
+	Flag     bool       `aper:"default:true"`
+	Count    int64      `aper:"valueLB:0,valueUB:15,default:3"`
+	Priority Enumerated `aper:"valueLB:0,valueUB:3,default:1"`
+	Name     string     `aper:"visible,sizeLB:1,sizeUB:8,default:'a,b'"`
+	Opt      *int64     `aper:"valueLB:0,valueUB:15,default:7"`
+}
+
+type defaultTest2 struct {
+	Value defaultExtStruct `aper:"valueExt"`
+}
+
+type defaultExtStruct struct {
+	Int1  int64  `aper:"valueLB:0,valueUB:255"`
+	Count *int64 `aper:"valueLB:0,valueUB:15,default:3,extAddition"`
+}
+
+var (
+	defaultOpt   int64 = 7
+	defaultCount int64 = 3
+	otherCount   int64 = 9
+)
+
+var defaultTestData = []testData{
+	{[]byte{0x00}, defaultTest1{true, 3, 1, "a,b", &defaultOpt}},
+	{[]byte{0x80}, defaultTest1{false, 3, 1, "a,b", &defaultOpt}},
+	{[]byte{0x42, 0x80}, defaultTest1{true, 5, 1, "a,b", &defaultOpt}},
+	{[]byte{0x11, 0x78, 0x79}, defaultTest1{true, 3, 1, "xy", &defaultOpt}},
+	{[]byte{0x00, 0x05}, defaultTest2{defaultExtStruct{5, &defaultCount}}},
+	{[]byte{0x80, 0x05, 0x01, 0x01, 0x90}, defaultTest2{defaultExtStruct{5, &otherCount}}},
+}
+
+func TestDefault(t *testing.T) {
+	testRoundTrip(t, defaultTestData)
+
+	// a nil pointer is encoded as the DEFAULT value
+	encoded, err := Marshal(defaultTest1{true, 3, 1, "a,b", nil})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00}, encoded)
+
+	// an absent extension addition has the DEFAULT value too
+	var x defaultTest2
+	assert.NoError(t, Unmarshal([]byte{0x80, 0x05, 0x00}, &x))
+	assert.Equal(t, defaultTest2{defaultExtStruct{5, &defaultCount}}, x)
+
+	// a DEFAULT value must be supported by the type of the field, even if it is not used
+	type unsupportedDefault struct {
+		Int1  int64       `aper:"valueLB:0,valueUB:255"`
+		Value OctetString `aper:"default:'ab'"`
+	}
+	_, err = Marshal(unsupportedDefault{1, OctetString("cd")})
+	assert.Error(t, err)
+	assert.Error(t, Unmarshal([]byte{0x80, 0x01, 0x02, 0x63, 0x64}, &unsupportedDefault{}))
+}
+
+// SET TEST
+type setTest1 struct {
+	Value setStruct `aper:"set"`
+}
+
+type setStruct struct {
+	Name OctetString `aper:"sizeLB:1,sizeUB:1"`
+	Flag bool
+	Num  int64  `aper:"valueLB:0,valueUB:7,tag:0"`
+	Opt  *int64 `aper:"valueLB:0,valueUB:3,optional,tag:application:1"`
+}
+
+type setTest2 struct {
+	Value []int64 `aper:"set,sizeLB:0,sizeUB:4,valueLB:0,valueUB:255"`
+}
+
+type setTest3 struct {
+	Value setReferenceStruct `aper:"set"`
+}
+
+// the open type refers to a field which is declared after it, but precedes it in canonical order
+type setReferenceStruct struct {
+	Value referenceValueStruct `aper:"openType,referenceFieldName:Id,tag:1"`
+	Id    int64                `aper:"valueLB:0,valueUB:255,tag:0"`
+}
+
+type setTest4 struct {
+	Value setReferenceStruct2 `aper:"set"`
+}
+
+// the open type refers to a field which is declared before it, but follows it in canonical order
+type setReferenceStruct2 struct {
+	Id    int64                `aper:"valueLB:0,valueUB:255,tag:1"`
+	Value referenceValueStruct `aper:"openType,referenceFieldName:Id,tag:0"`
+}
+
+var setOpt int64 = 2
+
+var setTestData = []testData{
+	{[]byte{0xd0, 0x6a}, setTest1{setStruct{OctetString("A"), true, 5, &setOpt}}},
+	{[]byte{0x60, 0x01, 0x02, 0x03}, setTest2{[]int64{1, 2, 3}}},
+	{[]byte{0x02, 0x01, 0x80}, setTest3{setReferenceStruct{referenceValueStruct{Present: 2, Bool: true}, 2}}},
+}
+
+func TestSet(t *testing.T) {
+	testRoundTrip(t, setTestData)
+
+	// the elements of a SET OF are sorted by their encodings
+	encoded, err := Marshal(setTest2{[]int64{3, 1, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, setTestData[1].in, encoded)
+
+	// the components of a SET must have distinct tags
+	_, err = MarshalWithParams(struct {
+		A int64 `aper:"valueLB:0,valueUB:7"`
+		B int64 `aper:"valueLB:0,valueUB:7"`
+	}{}, "set")
+	assert.Error(t, err)
+
+	// the open type is decoded before its reference
+	_, err = Marshal(setTest4{setReferenceStruct2{2, referenceValueStruct{Present: 2, Bool: true}}})
+	assert.Error(t, err)
+	assert.Error(t, Unmarshal([]byte{0x01, 0x80, 0x02}, &setTest4{}))
+}
+
+// BIG INTEGER TEST
+type bigIntTest1 struct {
+	Value *big.Int
+}
+
+type bigIntTest2 struct {
+	Value *big.Int `aper:"valueLB:-5"`
+}
+
+type bigIntTest3 struct {
+	Value *big.Int `aper:"valueLB:0,valueUB:18446744073709551615"`
+}
+
+type bigIntTest4 struct {
+	Value *big.Int `aper:"valueExt,valueLB:0,valueUB:100"`
+}
+
+type bigIntTest5 struct {
+	Value int64 `aper:"valueLB:0,valueUB:65536"`
+}
+
+func newBigInt(s string) *big.Int {
+	x, _ := new(big.Int).SetString(s, 0)
+	return x
+}
+
+var bigIntTestData = []testData{
+	{[]byte{0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, bigIntTest1{newBigInt("0x10000000000000000")}},
+	{[]byte{0x02, 0xff, 0x7f}, bigIntTest1{big.NewInt(-129)}},
+	{[]byte{0x01, 0xff}, bigIntTest2{big.NewInt(250)}},
+	{[]byte{0x20, 0x01, 0x00}, bigIntTest3{big.NewInt(256)}},
+	{
+		[]byte{0x80, 0x09, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		bigIntTest4{newBigInt("0x400000000000000000")},
+	},
+	{[]byte{0x3c}, bigIntTest4{big.NewInt(60)}},
+	{[]byte{0x80, 0x01, 0x00, 0x00}, bigIntTest5{65536}},
+}
+
+func TestBigInteger(t *testing.T) {
+	testRoundTrip(t, bigIntTestData)
+
+	_, err := Marshal(bigIntTest3{big.NewInt(-1)})
+	assert.Error(t, err)
+}
+
+// UNSIGNED INTEGER TEST
+type uintTest1 struct {
+	Value uint64 `aper:"valueLB:0,valueUB:18446744073709551615"`
+}
+
+type uintTest2 struct {
+	Value uint8
+}
+
+type uintTest3 struct {
+	Value uint16 `aper:"valueLB:0,valueUB:1000"`
+}
+
+type uintTest4 struct {
+	Value OctetString `aper:"sizeLB:0,sizeUB:18446744073709551615"`
+}
+
+var uintTestData = []testData{
+	{[]byte{0xe0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uintTest1{math.MaxUint64}},
+	{[]byte{0x00, 0x00}, uintTest1{0}},
+	{[]byte{0x02, 0x00, 0xc8}, uintTest2{200}},
+	{[]byte{0x03, 0xe8}, uintTest3{1000}},
+	{[]byte{0x02, 0x61, 0x62}, uintTest4{OctetString("ab")}},
+}
+
+func TestUnsignedInteger(t *testing.T) {
+	testRoundTrip(t, uintTestData)
+
+	err := Unmarshal([]byte{0x02, 0x01, 0x00}, &uintTest2{})
+	assert.Error(t, err)
+	_, err = Marshal(uintTest3{1001})
+	assert.Error(t, err)
+}
+
+// NARROW INTEGER TEST
+type narrowIntTest1 struct {
+	Value int8
+}
+
+type narrowIntTest2 struct {
+	Value int16 `aper:"valueLB:-1000,valueUB:1000"`
+}
+
+type narrowIntTest3 struct {
+	Value int32
+}
+
+var narrowIntTestData = []testData{
+	{[]byte{0x01, 0xfb}, narrowIntTest1{-5}},
+	{[]byte{0x07, 0xd0}, narrowIntTest2{1000}},
+	{[]byte{0x04, 0x80, 0x00, 0x00, 0x00}, narrowIntTest3{math.MinInt32}},
+}
+
+func TestNarrowInteger(t *testing.T) {
+	testRoundTrip(t, narrowIntTestData)
+
+	// values which do not fit the Go type are not truncated
+	err := Unmarshal([]byte{0x02, 0x01, 0x00}, &narrowIntTest1{})
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x05, 0x01, 0x00, 0x00, 0x00, 0x00}, &narrowIntTest3{})
+	assert.Error(t, err)
+
+	// nor are values which do not fit an int64
+	var semiConstrained semiConstrainedIntTest1
+	err = Unmarshal([]byte{0x08, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, &semiConstrained)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), semiConstrained.Value)
+	err = Unmarshal([]byte{0x08, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, &semiConstrained)
+	assert.Error(t, err)
+	err = Unmarshal([]byte{0x08, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, &semiConstrainedIntTest2{})
+	assert.Error(t, err)
+	var unconstrained struct {
+		Value int64
+	}
+	err = Unmarshal([]byte{0x09, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, &unconstrained)
+	assert.Error(t, err)
+	// nor are values of a large range above its upper bound
+	err = Unmarshal([]byte{0x80, 0x02, 0x00, 0x00}, &largeRangeIntTest1{})
+	assert.Error(t, err)
+}
+
+// SEQUENCE OF LENGTH TEST
+type sequenceOfLengthTest1 struct {
+	List []int64 `aper:"valueLB:0,valueUB:255"`
+}
+
+type sequenceOfLengthTest2 struct {
+	List []int64 `aper:"sizeExt,sizeLB:2,sizeUB:4,valueLB:0,valueUB:255"`
+}
+
+type sequenceOfLengthTest3 struct {
+	List []int64 `aper:"sizeLB:0,sizeUB:4,valueLB:0,valueUB:255"`
+}
+
+// sizes outside the extension root have a semi-constrained length
+var sequenceOfLengthTestData = []testData{
+	{[]byte{0x20, 0x01, 0x02, 0x03}, sequenceOfLengthTest2{[]int64{1, 2, 3}}},
+	{[]byte{0x80, 0x01, 0x07}, sequenceOfLengthTest2{[]int64{7}}},
+	{[]byte{0x80, 0x00}, sequenceOfLengthTest2{[]int64{}}},
+	{[]byte{0x80, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05}, sequenceOfLengthTest2{[]int64{1, 2, 3, 4, 5}}},
+}
+
+func TestSequenceOfLength(t *testing.T) {
+	for i, n := range []int{127, 200, 16383, 16384, 40000, 70000} {
+		perTestTrace(1, fmt.Sprintf("[TEST %d]\n", i+1))
+		test := sequenceOfLengthTest1{make([]int64, n)}
+		for j := range test.List {
+			test.List[j] = int64(j & 0xff)
+		}
+		encoded, err := Marshal(test)
+		assert.NoError(t, err, "TEST %d", i+1)
+
+		// each length determinant is followed by its elements of one octet
+		var expected []byte
+		done := 0
+		for _, header := range fragmentHeaders(n) {
+			expected = append(expected, header...)
+			for part := int(fragmentSize(uint64(n - done))); part > 0; part-- {
+				expected = append(expected, byte(done))
+				done++
+			}
+		}
+		assert.Equal(t, expected, encoded, "TEST %d", i+1)
+
+		var out sequenceOfLengthTest1
+		err = Unmarshal(encoded, &out)
+		assert.NoError(t, err, "TEST %d", i+1)
+		assert.Equal(t, test, out, "TEST %d", i+1)
+	}
+
+	testRoundTrip(t, sequenceOfLengthTestData)
+
+	// a missing length is an error rather than no elements
+	assert.Error(t, Unmarshal([]byte{}, &sequenceOfLengthTest3{}))
+	_, err := Marshal(sequenceOfLengthTest3{[]int64{1, 2, 3, 4, 5}})
+	assert.Error(t, err)
+}
+
+// FRAGMENTATION TEST
+type fragmentTest1 struct {
+	Value OctetString
+}
+
+type fragmentTest2 struct {
+	Value BitString
+}
+
+type fragmentTest3 struct {
+	Value OctetString `aper:"sizeLB:2"`
+}
+
+type fragmentTest4 struct {
+	Value BitString `aper:"sizeLB:1"`
+}
+
+type fragmentTest5 struct {
+	Value OctetString `aper:"sizeLB:1,sizeUB:70000"`
+}
+
+// without an upper bound below 64K, the length determinant is the length itself
+// rather than its offset from sizeLB (X.691 11.9.4.2)
+var semiConstrainedLengthTestData = []testData{
+	{[]byte{0x03, 0x01, 0x02, 0x03}, fragmentTest3{OctetString{0x01, 0x02, 0x03}}},
+	{[]byte{0x03, 0xA0}, fragmentTest4{BitString{[]byte{0xA0}, 3}}},
+	{[]byte{0x02, 0x01, 0x02}, fragmentTest5{OctetString{0x01, 0x02}}},
+}
+
+func TestSemiConstrainedLength(t *testing.T) {
+	testRoundTrip(t, semiConstrainedLengthTestData)
+}
+
+// fragmentHeaders returns the length determinants of the fragments of n units.
+func fragmentHeaders(n int) (headers [][]byte) {
+	for done, part := 0, 16384; part >= 16384; done += part {
+		part = int(fragmentSize(uint64(n - done)))
+		switch {
+		case part >= 16384:
+			headers = append(headers, []byte{byte(0xc0 | part>>14)})
+		case part >= 128:
+			headers = append(headers, []byte{byte(0x80 | part>>8), byte(part)})
+		default:
+			headers = append(headers, []byte{byte(part)})
+		}
+	}
+	return headers
+}
+
+func TestFragmentation(t *testing.T) {
+	for i, n := range []int{16383, 16384, 32768, 65536, 70000, 131072} {
+		perTestTrace(1, fmt.Sprintf("[TEST %d]\n", i+1))
+		contents := make([]byte, n)
+		for j := range contents {
+			contents[j] = byte(j)
+		}
+		var expected []byte
+		done := 0
+		for _, header := range fragmentHeaders(n) {
+			part := int(fragmentSize(uint64(n - done)))
+			expected = append(append(expected, header...), contents[done:done+part]...)
+			done += part
+		}
+
+		// OCTET STRING
+		encoded, err := Marshal(fragmentTest1{contents})
+		assert.NoError(t, err, "TEST %d", i+1)
+		assert.Equal(t, expected, encoded, "TEST %d", i+1)
+		var octetString fragmentTest1
+		assert.NoError(t, Unmarshal(encoded, &octetString), "TEST %d", i+1)
+		assert.Equal(t, OctetString(contents), octetString.Value, "TEST %d", i+1)
+
+		// open type
+		pd := &perRawBitData{[]byte{}, 0, nil}
+		assert.NoError(t, pd.appendOpenTypeContents(contents), "TEST %d", i+1)
+		assert.Equal(t, expected, pd.bytes, "TEST %d", i+1)
+		openType, err := (&perBitData{pd.bytes, 0, 0, nil}).parseOpenTypeContents()
+		assert.NoError(t, err, "TEST %d", i+1)
+		assert.Equal(t, contents, openType, "TEST %d", i+1)
+	}
+
+	// BIT STRING fragments count bits
+	bitString := BitString{make([]byte, 5000), 40000}
+	encoded, err := Marshal(fragmentTest2{bitString})
+	assert.NoError(t, err)
+	expected := append([]byte{0xc2}, bitString.Bytes[:4096]...)
+	expected = append(append(expected, 0x9c, 0x40), bitString.Bytes[4096:]...)
+	assert.Equal(t, expected, encoded)
+	var out fragmentTest2
+	assert.NoError(t, Unmarshal(encoded, &out))
+	assert.Equal(t, bitString, out.Value)
+
+	// a semi-constrained length is not an offset from the lower bound
+	encoded, err = Marshal(fragmentTest3{OctetString("abc")})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x61, 0x62, 0x63}, encoded)
+}
+
+// SEQUENCE OF ELEMENT TEST
+type elementTest1 struct {
+	List []OctetString `aper:"sizeLB:1,sizeUB:16,elem:sizeLB:3,elem:sizeUB:3"`
+}
+
+type elementTest2 struct {
+	List []int64 `aper:"sizeLB:0,sizeUB:3,elem:valueLB:10,elem:valueUB:13"`
+}
+
+type elementTest3 struct {
+	List []string `aper:"sizeLB:2,sizeUB:2,elem:visible,elem:from:'a,b'"`
+}
+
+var elementTestData = []testData{
+	{[]byte{0x10, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66}, elementTest1{[]OctetString{[]byte("abc"), []byte("def")}}},
+	{[]byte{0x8c}, elementTest2{[]int64{10, 13}}},
+	{[]byte{0x02, 0x40, 0x01, 0x80}, elementTest3{[]string{"a,", "b"}}},
+}
+
+func TestSequenceOfElement(t *testing.T) {
+	testRoundTrip(t, elementTestData)
+
+	_, err := Marshal(elementTest1{[]OctetString{OctetString("abcd")}})
+	assert.Error(t, err)
+}
+
+// NESTED SEQUENCE OF TEST
+type nestedTest1 struct {
+	Value [][]int64 `aper:"sizeLB:1,sizeUB:4,elem:sizeLB:1,elem:sizeUB:2,elem:elem:valueLB:0,elem:elem:valueUB:7"`
+}
+
+type nestedRow []uint16
+
+type nestedTable []nestedRow
+
+type nestedTest2 struct {
+	Value nestedTable `aper:"sizeLB:0,sizeUB:3,elem:sizeLB:0,elem:sizeUB:3,elem:elem:valueLB:0,elem:elem:valueUB:65535"`
+}
+
+type nestedTest3 struct {
+	Value []byte `aper:"sizeLB:1,sizeUB:8"`
+}
+
+type nestedTest4 struct {
+	Value [][]byte `aper:"sizeLB:0,sizeUB:3,elem:sizeLB:2,elem:sizeUB:2"`
+}
+
+var nestedTestData = []testData{
+	{[]byte{0x46, 0x98}, nestedTest1{[][]int64{{1}, {2, 3}}}},
+	{[]byte{0x90, 0x00, 0x01, 0x00}, nestedTest2{nestedTable{{1}, {}}}},
+	{[]byte{0x20, 0x61, 0x62}, nestedTest3{[]byte("ab")}},
+	{[]byte{0x98, 0x58, 0x98, 0xd9, 0x00}, nestedTest4{[][]byte{[]byte("ab"), []byte("cd")}}},
+}
+
+func TestNestedSequenceOf(t *testing.T) {
+	testRoundTrip(t, nestedTestData)
+
+	// the constraints of each level are checked
+	_, err := Marshal(nestedTest1{[][]int64{{1, 2, 3}}})
+	assert.Error(t, err)
+	_, err = Marshal(nestedTest1{[][]int64{{8}}})
+	assert.Error(t, err)
+}
+
 // BOOLEAN TEST
 type boolTest1 struct {
 	Value bool
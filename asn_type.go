@@ -5,7 +5,11 @@
 package aper
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"time"
 )
 
 // BIT STRING
@@ -23,14 +27,320 @@ type OctetString []byte
 
 // OBJECT IDENTIFIER
 
-// ObjectIdentifier is for an ASN.1 OBJECT IDENTIFIER type
+// ObjectIdentifier is for an ASN.1 OBJECT IDENTIFIER type, it holds the BER contents octets.
 type ObjectIdentifier []byte
 
+// NewObjectIdentifier returns the ObjectIdentifier of the arcs.
+func NewObjectIdentifier(arcs []uint64) (ObjectIdentifier, error) {
+	if len(arcs) < 2 {
+		return nil, fmt.Errorf("object identifier needs at least two arcs")
+	} else if arcs[0] > 2 {
+		return nil, fmt.Errorf("first arc of object identifier is larger than 2: %d", arcs[0])
+	} else if arcs[0] < 2 && arcs[1] > 39 {
+		return nil, fmt.Errorf("second arc of object identifier is larger than 39: %d", arcs[1])
+	} else if arcs[1] > math.MaxUint64-80 {
+		return nil, fmt.Errorf("second arc of object identifier is too large: %d", arcs[1])
+	}
+	oid := appendBase128(nil, arcs[0]*40+arcs[1])
+	for _, arc := range arcs[2:] {
+		oid = appendBase128(oid, arc)
+	}
+	return oid, nil
+}
+
+// ParseObjectIdentifier returns the ObjectIdentifier of a dotted string such as "1.3.6.1.4.1.53148".
+func ParseObjectIdentifier(s string) (ObjectIdentifier, error) {
+	arcs, err := parseDottedArcs(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewObjectIdentifier(arcs)
+}
+
+// Arcs returns the arcs of the ObjectIdentifier.
+func (oid ObjectIdentifier) Arcs() ([]uint64, error) {
+	subIdentifiers, err := parseBase128(oid)
+	if err != nil {
+		return nil, err
+	} else if len(subIdentifiers) == 0 {
+		return nil, fmt.Errorf("object identifier is empty")
+	}
+	var arcs []uint64
+	switch first := subIdentifiers[0]; {
+	case first < 40:
+		arcs = []uint64{0, first}
+	case first < 80:
+		arcs = []uint64{1, first - 40}
+	default:
+		arcs = []uint64{2, first - 80}
+	}
+	return append(arcs, subIdentifiers[1:]...), nil
+}
+
+// String returns the dotted string of the ObjectIdentifier.
+func (oid ObjectIdentifier) String() string {
+	arcs, err := oid.Arcs()
+	if err != nil {
+		return fmt.Sprintf("ObjectIdentifier(%x)", []byte(oid))
+	}
+	return formatDottedArcs(arcs)
+}
+
+// RELATIVE-OID
+
+// RelativeOID is for an ASN.1 RELATIVE-OID type, it holds the BER contents octets.
+type RelativeOID []byte
+
+// NewRelativeOID returns the RelativeOID of the arcs.
+func NewRelativeOID(arcs []uint64) (RelativeOID, error) {
+	if len(arcs) == 0 {
+		return nil, fmt.Errorf("relative object identifier needs at least one arc")
+	}
+	var roid RelativeOID
+	for _, arc := range arcs {
+		roid = appendBase128(roid, arc)
+	}
+	return roid, nil
+}
+
+// ParseRelativeOID returns the RelativeOID of a dotted string such as "8571.3.2".
+func ParseRelativeOID(s string) (RelativeOID, error) {
+	arcs, err := parseDottedArcs(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewRelativeOID(arcs)
+}
+
+// Arcs returns the arcs of the RelativeOID.
+func (roid RelativeOID) Arcs() ([]uint64, error) {
+	arcs, err := parseBase128(roid)
+	if err != nil {
+		return nil, err
+	} else if len(arcs) == 0 {
+		return nil, fmt.Errorf("relative object identifier is empty")
+	}
+	return arcs, nil
+}
+
+// String returns the dotted string of the RelativeOID.
+func (roid RelativeOID) String() string {
+	arcs, err := roid.Arcs()
+	if err != nil {
+		return fmt.Sprintf("RelativeOID(%x)", []byte(roid))
+	}
+	return formatDottedArcs(arcs)
+}
+
+// NULL
+
+// Null is for an ASN.1 NULL type, it has no encoding.
+type Null struct{}
+
 // ENUMERATED
 
 // An Enumerated is represented as a plain uint64.
 type Enumerated uint64
 
+// TIME TYPES
+
+// Time is an ASN.1 TIME in its ISO 8601 form, such as "2023-05-06T07:08:09Z". It is
+// encoded as a VisibleString.
+type Time string
+
+// Date is an ASN.1 DATE, which is encoded as DATE-ENCODING (X.691 32.3).
+type Date struct {
+	Year  int64
+	Month int64 // 1..12
+	Day   int64 // 1..31
+}
+
+// String returns the ISO 8601 form (YYYY-MM-DD) of the Date.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// TimeOfDay is an ASN.1 TIME-OF-DAY, which is encoded as TIME-OF-DAY-ENCODING (X.691 32.4).
+type TimeOfDay struct {
+	Hours   int64 `aper:"valueLB:0,valueUB:24"`
+	Minutes int64 `aper:"valueLB:0,valueUB:59"`
+	Seconds int64 `aper:"valueLB:0,valueUB:60"`
+}
+
+// String returns the ISO 8601 form (hh:mm:ss) of the TimeOfDay.
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hours, t.Minutes, t.Seconds)
+}
+
+// DateTime is an ASN.1 DATE-TIME, which is encoded as DATE-TIME-ENCODING (X.691 32.5).
+type DateTime struct {
+	Date Date
+	Time TimeOfDay
+}
+
+// String returns the ISO 8601 form (YYYY-MM-DDThh:mm:ss) of the DateTime.
+func (dt DateTime) String() string {
+	return dt.Date.String() + "T" + dt.Time.String()
+}
+
+// Duration is an ASN.1 DURATION, which is encoded as DURATION-INTERVAL-ENCODING
+// (X.691 32.6). A nil component is absent.
+type Duration struct {
+	Years          *int64            `aper:"valueExt,valueLB:0,valueUB:31,optional"`
+	Months         *int64            `aper:"valueExt,valueLB:0,valueUB:15,optional"`
+	Weeks          *int64            `aper:"valueExt,valueLB:0,valueUB:63,optional"`
+	Days           *int64            `aper:"valueExt,valueLB:0,valueUB:31,optional"`
+	Hours          *int64            `aper:"valueExt,valueLB:0,valueUB:31,optional"`
+	Minutes        *int64            `aper:"valueExt,valueLB:0,valueUB:63,optional"`
+	Seconds        *int64            `aper:"valueExt,valueLB:0,valueUB:63,optional"`
+	FractionalPart *DurationFraction `aper:"optional"`
+}
+
+// DurationFraction is the fractional part of the last component of a Duration, that is
+// FractionalValue / 10^NumberOfDigits.
+type DurationFraction struct {
+	NumberOfDigits  int64 `aper:"valueExt,valueLB:1,valueUB:3"`
+	FractionalValue int64 `aper:"valueExt,valueLB:0,valueUB:999"`
+}
+
+// dateEncoding is the DATE-ENCODING which a Date is encoded as.
+type dateEncoding struct {
+	Year  yearEncoding `aper:"valueLB:0,valueUB:3"`
+	Month int64        `aper:"valueLB:1,valueUB:12"`
+	Day   int64        `aper:"valueLB:1,valueUB:31"`
+}
+
+// yearEncoding is the YEAR-ENCODING CHOICE of a DATE-ENCODING.
+type yearEncoding struct {
+	Present    int
+	Immediate  int64 `aper:"valueLB:2005,valueUB:2020"`
+	NearFuture int64 `aper:"valueLB:2021,valueUB:2276"`
+	NearPast   int64 `aper:"valueLB:1749,valueUB:2004"`
+	Remainder  int64
+}
+
+// newDateEncoding returns the DATE-ENCODING of the Date.
+func newDateEncoding(d Date) dateEncoding {
+	enc := dateEncoding{Month: d.Month, Day: d.Day}
+	switch {
+	case d.Year >= 2005 && d.Year <= 2020:
+		enc.Year = yearEncoding{Present: 1, Immediate: d.Year}
+	case d.Year >= 2021 && d.Year <= 2276:
+		enc.Year = yearEncoding{Present: 2, NearFuture: d.Year}
+	case d.Year >= 1749 && d.Year <= 2004:
+		enc.Year = yearEncoding{Present: 3, NearPast: d.Year}
+	default:
+		enc.Year = yearEncoding{Present: 4, Remainder: d.Year}
+	}
+	return enc
+}
+
+// date returns the Date of the DATE-ENCODING.
+func (enc dateEncoding) date() (Date, error) {
+	d := Date{Month: enc.Month, Day: enc.Day}
+	switch enc.Year.Present {
+	case 1:
+		d.Year = enc.Year.Immediate
+	case 2:
+		d.Year = enc.Year.NearFuture
+	case 3:
+		d.Year = enc.Year.NearPast
+	case 4:
+		d.Year = enc.Year.Remainder
+		if d.Year >= 1749 && d.Year <= 2276 {
+			return d, fmt.Errorf("year %d is not in the remainder range of YEAR-ENCODING", d.Year)
+		}
+	default:
+		return d, fmt.Errorf("unknown YEAR-ENCODING alternative %d", enc.Year.Present)
+	}
+	return d, nil
+}
+
+// EXTENSIONS
+
+// RawExtension is an extension addition of a SEQUENCE, or an extension alternative of a
+// CHOICE, which is not declared in the struct. Index is its position in the extension
+// addition bitmap (SEQUENCE) or its index among the extension alternatives (CHOICE),
+// Bytes is the open type contents of its value.
+type RawExtension struct {
+	Index uint64
+	Bytes []byte
+}
+
+// RawExtensions keeps the unknown extensions of a SEQUENCE or a CHOICE, so that they
+// are re-encoded verbatim.
+type RawExtensions []RawExtension
+
+// UnknownOpenType keeps the contents of an open type whose reference value does not
+// match any field, so that it is re-encoded verbatim.
+type UnknownOpenType []byte
+
+// PROTOCOL CONTAINERS
+
+// Criticality values of the protocol containers.
+const (
+	CriticalityReject Enumerated = 0
+	CriticalityIgnore Enumerated = 1
+	CriticalityNotify Enumerated = 2
+)
+
+// ProtocolIEContainer is a ProtocolIE-Container of up to maxProtocolIEs fields. The value
+// type T is either a CHOICE struct whose alternatives are tagged with referenceFieldValue,
+// or an interface type whose object set is registered by RegisterOpenType.
+type ProtocolIEContainer[T any] struct {
+	List []ProtocolIEField[T] `aper:"sizeLB:0,sizeUB:65535"`
+}
+
+// ProtocolIEField is a ProtocolIE-Field, whose Value is the open type of the IE Id.
+type ProtocolIEField[T any] struct {
+	Id          int64      `aper:"valueLB:0,valueUB:65535"`
+	Criticality Enumerated `aper:"valueLB:0,valueUB:2"`
+	Value       T          `aper:"openType,referenceFieldName:Id"`
+}
+
+// ProtocolExtensionContainer is a ProtocolExtensionContainer of 1 to maxProtocolExtensions
+// fields, with a value type T as in ProtocolIEContainer.
+type ProtocolExtensionContainer[T any] struct {
+	List []ProtocolExtensionField[T] `aper:"sizeLB:1,sizeUB:65535"`
+}
+
+// ProtocolExtensionField is a ProtocolExtensionField, whose ExtensionValue is the open
+// type of the extension Id.
+type ProtocolExtensionField[T any] struct {
+	Id             int64      `aper:"valueLB:0,valueUB:65535"`
+	Criticality    Enumerated `aper:"valueLB:0,valueUB:2"`
+	ExtensionValue T          `aper:"openType,referenceFieldName:Id"`
+}
+
+// PrivateIEContainer is a PrivateIE-Container of 1 to maxPrivateIEs fields, with a value
+// type T as in ProtocolIEContainer.
+type PrivateIEContainer[T any] struct {
+	List []PrivateIEField[T] `aper:"sizeLB:1,sizeUB:65535"`
+}
+
+// PrivateIEField is a PrivateIE-Field, whose Value is the open type of the IE Id. Only
+// a local Id can be the reference value of the Value, so the Value of a global Id is
+// decoded as UnknownOpenType, or skipped if T cannot hold it.
+type PrivateIEField[T any] struct {
+	Id          PrivateIEID `aper:"valueLB:0,valueUB:1"`
+	Criticality Enumerated  `aper:"valueLB:0,valueUB:2"`
+	Value       T           `aper:"openType,referenceFieldName:Id"`
+}
+
+// PrivateIEID is the PrivateIE-ID CHOICE of a PrivateIEField.
+type PrivateIEID struct {
+	Present int
+	Local   *int64 `aper:"valueLB:0,valueUB:65535"`
+	Global  *ObjectIdentifier
+}
+
+// Present values of PrivateIEID.
+const (
+	PrivateIEIDPresentNothing int = iota
+	PrivateIEIDPresentLocal
+	PrivateIEIDPresentGlobal
+)
+
 var (
 	// BitStringType is the type of BitString
 	BitStringType = reflect.TypeOf(BitString{})
@@ -38,6 +348,23 @@ var (
 	OctetStringType = reflect.TypeOf(OctetString{})
 	// ObjectIdentifierType is the type of ObjectIdentify
 	ObjectIdentifierType = reflect.TypeOf(ObjectIdentifier{})
+	// RelativeOIDType is the type of RelativeOID
+	RelativeOIDType = reflect.TypeOf(RelativeOID{})
+	// NullType is the type of Null
+	NullType = reflect.TypeOf(Null{})
 	// EnumeratedType is the type of Enumerated
 	EnumeratedType = reflect.TypeOf(Enumerated(0))
+	// RawExtensionsType is the type of RawExtensions
+	RawExtensionsType = reflect.TypeOf(RawExtensions{})
+	// UnknownOpenTypeType is the type of UnknownOpenType
+	UnknownOpenTypeType = reflect.TypeOf(UnknownOpenType{})
+	// TimeType is the type of Time
+	TimeType = reflect.TypeOf(Time(""))
+	// DateType is the type of Date
+	DateType = reflect.TypeOf(Date{})
+
+	// goTimeType is the type of time.Time, which is a GeneralizedTime or UTCTime
+	goTimeType = reflect.TypeOf(time.Time{})
+	// bigIntType is the type of big.Int, which is an INTEGER of any size
+	bigIntType = reflect.TypeOf(big.Int{})
 )
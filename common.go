@@ -5,30 +5,58 @@
 package aper
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // fieldParameters is the parsed representation of tag string from a structure field.
 type fieldParameters struct {
-	optional            bool   // true iff the type has OPTIONAL tag.
-	sizeExtensible      bool   // true iff the size can be extensed.
-	valueExtensible     bool   // true iff the value can be extensed.
-	sizeLowerBound      *int64 // a sizeLowerBound is the minimum size of type constraint(maybe nil).
-	sizeUpperBound      *int64 // a sizeUpperBound is the maximum size of type constraint(maybe nil).
-	valueLowerBound     *int64 // a valueLowerBound is the minimum value of type constraint(maybe nil).
-	valueUpperBound     *int64 // a valueUpperBound is the maximum value of type constraint(maybe nil).
-	defaultValue        *int64 // a default value for INTEGER and ENUMERATED typed fields (maybe nil).
-	openType            bool   // true iff this type is opentype.
-	referenceFieldName  string // the field to get to get the corresrponding value of this type(maybe nil).
-	referenceFieldValue *int64 // the field value which map to this type(maybe nil).
+	optional            bool                // true iff the type has OPTIONAL tag.
+	sizeExtensible      bool                // true iff the size can be extensed.
+	valueExtensible     bool                // true iff the value can be extensed.
+	sizeLowerBound      *int64              // a sizeLowerBound is the minimum size of type constraint(maybe nil).
+	sizeUpperBound      *int64              // a sizeUpperBound is the maximum size of type constraint(maybe nil).
+	valueLowerBound     *int64              // a valueLowerBound is the minimum value of type constraint(maybe nil).
+	valueUpperBound     *int64              // a valueUpperBound is the maximum value of type constraint(maybe nil).
+	defaultValue        *string             // the DEFAULT value of the field(maybe nil).
+	openType            bool                // true iff this type is opentype.
+	referenceFieldName  string              // the field to get to get the corresrponding value of this type(maybe nil).
+	referenceFieldValue *int64              // the field value which map to this type(maybe nil).
+	globalReference     bool                // true iff the reference field is an OBJECT IDENTIFIER, as a global id.
+	extensionAddition   bool                // true iff the field is an extension addition of the SEQUENCE.
+	extensionGroup      *int64              // the extension addition group which the field belongs to(maybe nil).
+	stringType          characterStringType // the restricted character string type of a string field.
+	permittedAlphabet   string              // the permitted alphabet (FROM) of a character string(maybe empty).
+	timeFormat          timeFormat          // the useful time type of a time.Time field.
+	set                 bool                // true iff the struct is a SET, or the slice is a SET OF.
+	tag                 *asnTag             // the tag of the field, for the canonical order of SET(maybe nil).
+	bigValueLowerBound  *big.Int            // the valueLB of any size, for *big.Int fields(maybe nil).
+	bigValueUpperBound  *big.Int            // the valueUB of any size, for *big.Int fields(maybe nil).
+	elementTag          string              // the tag parts (prefixed with elem:) for the elements of a SEQUENCE OF.
+	objectSet           string              // the information object set of an interface{} open type(maybe empty).
 }
 
 // Given a tag string with the format specified in the package comment,
 // parseFieldParameters will parse it into a fieldParameters structure,
-// ignoring unknown parts of the string. TODO:PrintableString
+// ignoring unknown parts of the string.
 func parseFieldParameters(str string) (params fieldParameters) {
-	for _, part := range strings.Split(str, ",") {
+	params.parseTag(str)
+	return params
+}
+
+// parseTag parses the parts of the tag string str into params, overriding the
+// parameters which are already set.
+func (params *fieldParameters) parseTag(str string) {
+	for _, part := range splitTagParts(str) {
 		switch {
 		case part == "optional":
 			params.optional = true
@@ -44,6 +72,10 @@ func parseFieldParameters(str string) (params fieldParameters) {
 			}
 		case strings.HasPrefix(part, "sizeUB:"):
 			i, err := strconv.ParseInt(part[7:], 10, 64)
+			if _, errUint := strconv.ParseUint(part[7:], 10, 64); err != nil && errUint == nil {
+				// a size above MaxInt64 is as good as unbounded
+				i, err = math.MaxInt64, nil
+			}
 			if err == nil {
 				params.sizeUpperBound = new(int64)
 				*params.sizeUpperBound = i
@@ -54,20 +86,64 @@ func parseFieldParameters(str string) (params fieldParameters) {
 				params.valueLowerBound = new(int64)
 				*params.valueLowerBound = i
 			}
+			if b, ok := new(big.Int).SetString(part[8:], 10); ok {
+				params.bigValueLowerBound = b
+			}
 		case strings.HasPrefix(part, "valueUB:"):
 			i, err := strconv.ParseInt(part[8:], 10, 64)
 			if err == nil {
 				params.valueUpperBound = new(int64)
 				*params.valueUpperBound = i
 			}
+			if b, ok := new(big.Int).SetString(part[8:], 10); ok {
+				params.bigValueUpperBound = b
+			}
 		case strings.HasPrefix(part, "default:"):
-			i, err := strconv.ParseInt(part[8:], 10, 64)
+			params.defaultValue = new(string)
+			*params.defaultValue = unquoteTagValue(part[8:])
+		case part == "numeric":
+			params.stringType = stringTypeNumeric
+		case part == "printable":
+			params.stringType = stringTypePrintable
+		case part == "ia5":
+			params.stringType = stringTypeIA5
+		case part == "visible":
+			params.stringType = stringTypeVisible
+		case part == "utf8":
+			params.stringType = stringTypeUTF8
+		case part == "bmp":
+			params.stringType = stringTypeBMP
+		case part == "universal":
+			params.stringType = stringTypeUniversal
+		case part == "generalized":
+			params.timeFormat = timeFormatGeneralized
+		case part == "utc":
+			params.timeFormat = timeFormatUTC
+		case strings.HasPrefix(part, "elem:"):
+			if params.elementTag != "" {
+				params.elementTag += ","
+			}
+			params.elementTag += part[5:]
+		case part == "set":
+			params.set = true
+		case strings.HasPrefix(part, "tag:"):
+			tag, err := parseASNTag(part[4:])
 			if err == nil {
-				params.defaultValue = new(int64)
-				*params.defaultValue = i
+				params.tag = tag
 			}
+		case strings.HasPrefix(part, "from:"):
+			params.permittedAlphabet = unquoteTagValue(part[5:])
 		case part == "openType":
 			params.openType = true
+		case part == "extAddition":
+			params.extensionAddition = true
+		case strings.HasPrefix(part, "extGroup:"):
+			i, err := strconv.ParseInt(part[9:], 10, 64)
+			if err == nil {
+				params.extensionAddition = true
+				params.extensionGroup = new(int64)
+				*params.extensionGroup = i
+			}
 		case strings.HasPrefix(part, "referenceFieldName:"):
 			params.referenceFieldName = part[19:]
 		case strings.HasPrefix(part, "referenceFieldValue:"):
@@ -76,7 +152,890 @@ func parseFieldParameters(str string) (params fieldParameters) {
 				params.referenceFieldValue = new(int64)
 				*params.referenceFieldValue = i
 			}
+		case strings.HasPrefix(part, "objectSet:"):
+			params.objectSet = part[10:]
 		}
 	}
+}
+
+// getElementParameters returns the parameters of the elements of a SEQUENCE OF
+// or SET OF: those of the list without its own size constraint, overridden by
+// the elem: parts of its tag.
+func getElementParameters(params fieldParameters) fieldParameters {
+	elementTag := params.elementTag
+	params.sizeExtensible = false
+	params.sizeUpperBound = nil
+	params.sizeLowerBound = nil
+	params.set = false
+	params.elementTag = ""
+	params.parseTag(elementTag)
 	return params
 }
+
+// splitTagParts splits a tag string at the commas which are not inside a
+// single-quoted value, such as the permitted alphabet in from:'A,B'.
+func splitTagParts(str string) (parts []string) {
+	quoted := false
+	start := 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, str[start:])
+}
+
+// unquoteTagValue removes the single quotes around a tag value, in which a
+// single quote is written as two single quotes.
+func unquoteTagValue(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+// sequenceAncestor is an enclosing SEQUENCE or SET of the value being encoded or decoded,
+// with the index sequence of its component which contains the value.
+type sequenceAncestor struct {
+	value reflect.Value
+	index []int
+	order [][]int // the index sequences of the root components of a SET in canonical order(maybe nil).
+}
+
+// newSequenceAncestor returns the sequenceAncestor of the SEQUENCE or SET val, whose root
+// components are the fields of root in the order of their encoding.
+func newSequenceAncestor(val reflect.Value, fields []reflect.StructField, root []int, set bool) sequenceAncestor {
+	ancestor := sequenceAncestor{value: val}
+	if set {
+		for _, i := range root {
+			ancestor.order = append(ancestor.order, fields[i].Index)
+		}
+	}
+	return ancestor
+}
+
+// precedes reports whether the component with the index sequence index is encoded before
+// the component which contains the value. The components of a SEQUENCE are encoded in the
+// order of the struct fields, and the root components of a SET in canonical order before
+// its extension additions.
+func (ancestor sequenceAncestor) precedes(index []int) bool {
+	for _, componentIndex := range ancestor.order {
+		if slices.Equal(componentIndex, ancestor.index) {
+			return false
+		} else if slices.Equal(componentIndex, index) {
+			return true
+		}
+	}
+	return slices.Compare(index, ancestor.index) < 0
+}
+
+// extensionAddition is one entry of the extension addition bitmap of a SEQUENCE:
+// either a single field, or the fields of an extension addition group ([[ ... ]]).
+type extensionAddition struct {
+	fields []int
+	group  bool
+}
+
+// splitExtensionAdditions returns the indexes of the fields in the extension root
+// and the extension additions of a SEQUENCE, both in declaration order. Consecutive
+// fields tagged with the same extGroup number form one extension addition group.
+// A RawExtensions field belongs to neither. The fields of the extension additions
+// must be pointers, slices or interfaces, which are nil when absent.
+func splitExtensionAdditions(fields []reflect.StructField, structParams []fieldParameters) (root []int,
+	additions []extensionAddition, err error,
+) {
+	for i, params := range structParams {
+		switch {
+		case fields[i].Type == RawExtensionsType:
+			continue
+		case !params.extensionAddition:
+			root = append(root, i)
+		case !isNillable(fields[i].Type):
+			return nil, nil, fmt.Errorf("extension addition \"%s\" is %s rather than a pointer, slice or interface",
+				fields[i].Name, fields[i].Type)
+		case params.extensionGroup == nil:
+			additions = append(additions, extensionAddition{fields: []int{i}})
+		default:
+			last := len(additions) - 1
+			if last >= 0 && additions[last].group &&
+				*structParams[additions[last].fields[0]].extensionGroup == *params.extensionGroup {
+				additions[last].fields = append(additions[last].fields, i)
+			} else {
+				additions = append(additions, extensionAddition{fields: []int{i}, group: true})
+			}
+		}
+	}
+	return root, additions, nil
+}
+
+// isNillable reports whether a field of type t can be nil, and so absent.
+func isNillable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// isPresent reports whether the extension addition, or any field of the
+// extension addition group, of the SEQUENCE v has a value other than its DEFAULT
+// value, which is checked when the struct is parsed.
+func (addition extensionAddition) isPresent(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters,
+) bool {
+	for _, i := range addition.fields {
+		field := v.FieldByIndex(fields[i].Index)
+		if field.IsNil() {
+			continue
+		} else if structParams[i].defaultValue == nil {
+			return true
+		} else if isDefault, err := isDefaultValue(field, *structParams[i].defaultValue); err != nil || !isDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// setDefaultValues sets the fields of the extension addition, or of the extension
+// addition group, of the SEQUENCE v which is absent from the encoding to their
+// DEFAULT values.
+func (addition extensionAddition) setDefaultValues(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters,
+) error {
+	for _, i := range addition.fields {
+		if structParams[i].defaultValue != nil {
+			if err := setDefaultValue(v.FieldByIndex(fields[i].Index), *structParams[i].defaultValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isOptionalOrDefault reports whether the field has a bit in the presence bitmap of its SEQUENCE.
+func (params fieldParameters) isOptionalOrDefault() bool {
+	return params.optional || params.defaultValue != nil
+}
+
+// getDefaultValue returns the DEFAULT value of a field of type t (or *t).
+func getDefaultValue(t reflect.Type, defaultValue string) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(defaultValue)
+		if err != nil {
+			return v, fmt.Errorf("invalid DEFAULT value %q of %s: %v", defaultValue, t, err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(defaultValue, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("invalid DEFAULT value %q of %s: %v", defaultValue, t, err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(defaultValue, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("invalid DEFAULT value %q of %s: %v", defaultValue, t, err)
+		}
+		v.SetUint(u)
+	case reflect.String:
+		v.SetString(defaultValue)
+	default:
+		return v, fmt.Errorf("DEFAULT value of %s is unsupported", t)
+	}
+	return v, nil
+}
+
+// isDefaultValue reports whether the field v has its DEFAULT value, so that it is
+// omitted from the encoding. A nil pointer has the DEFAULT value.
+func isDefaultValue(v reflect.Value, defaultValue string) (bool, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true, nil
+		}
+		v = v.Elem()
+	}
+	d, err := getDefaultValue(v.Type(), defaultValue)
+	if err != nil {
+		return false, err
+	}
+	return v.Equal(d), nil
+}
+
+// setDefaultValue sets the field v, which is absent from the encoding, to its DEFAULT value.
+func setDefaultValue(v reflect.Value, defaultValue string) error {
+	d, err := getDefaultValue(v.Type(), defaultValue)
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(d.Type()))
+		v = v.Elem()
+	}
+	v.Set(d)
+	return nil
+}
+
+// getRawExtensionsIndex returns the index of the RawExtensions field in the fields of a
+// SEQUENCE or CHOICE struct, or -1 if it does not have one.
+func getRawExtensionsIndex(fields []reflect.StructField) int {
+	for i, field := range fields {
+		if field.Type == RawExtensionsType {
+			return i
+		}
+	}
+	return -1
+}
+
+// getStructFields returns the fields of the SEQUENCE, SET or CHOICE struct type t. The
+// fields of an anonymous embedded SEQUENCE or SET without a tag are spliced into a SEQUENCE
+// or SET in place of it as COMPONENTS OF, which leaves out its extension additions and
+// RawExtensions field (X.680 25.5). The Index of each field is its index sequence in t.
+func getStructFields(t reflect.Type) (fields []reflect.StructField) {
+	choice := t.NumField() > 0 && t.Field(0).Name == PRESENT
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if choice || !isComponentsOf(field) {
+			fields = append(fields, field)
+			continue
+		}
+		for _, component := range getStructFields(field.Type) {
+			params := parseFieldParameters(component.Tag.Get("aper"))
+			if params.extensionAddition || component.Type == RawExtensionsType {
+				continue
+			}
+			component.Index = append([]int{i}, component.Index...)
+			fields = append(fields, component)
+		}
+	}
+	return fields
+}
+
+// isComponentsOf reports whether the struct field is an anonymous embedded SEQUENCE or
+// SET without a tag, whose components are spliced into the enclosing struct.
+func isComponentsOf(field reflect.StructField) bool {
+	if !field.Anonymous || field.Type.Kind() != reflect.Struct || field.Tag.Get("aper") != "" {
+		return false
+	}
+	switch field.Type {
+	case BitStringType, NullType, DateType, goTimeType, bigIntType:
+		return false
+	}
+	return field.Type.NumField() == 0 || field.Type.Field(0).Name != PRESENT
+}
+
+// appendBase128 appends the subidentifier value to b in the base 128 form of the
+// BER contents octets of OBJECT IDENTIFIER and RELATIVE-OID (X.690 8.19.2).
+func appendBase128(b []byte, value uint64) []byte {
+	var n int
+	for tmp := value; tmp > 0x7f; tmp >>= 7 {
+		n++
+	}
+	for ; n > 0; n-- {
+		b = append(b, byte(value>>(7*uint(n)))|0x80)
+	}
+	return append(b, byte(value&0x7f))
+}
+
+// parseBase128 returns the subidentifiers of the BER contents octets of
+// OBJECT IDENTIFIER and RELATIVE-OID.
+func parseBase128(b []byte) (values []uint64, err error) {
+	var value uint64
+	for i, octet := range b {
+		if value == 0 && octet == 0x80 {
+			return nil, fmt.Errorf("subidentifier is not encoded in the fewest octets")
+		} else if value > math.MaxUint64>>7 {
+			return nil, fmt.Errorf("subidentifier is too large")
+		}
+		value = value<<7 | uint64(octet&0x7f)
+		if octet&0x80 == 0 {
+			values = append(values, value)
+			value = 0
+		} else if i == len(b)-1 {
+			return nil, fmt.Errorf("subidentifier is truncated")
+		}
+	}
+	return values, nil
+}
+
+// parseDottedArcs returns the arcs of a dotted string such as "1.3.6.1".
+func parseDottedArcs(s string) ([]uint64, error) {
+	var arcs []uint64
+	for _, part := range strings.Split(s, ".") {
+		arc, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid arc %q in %q", part, s)
+		}
+		arcs = append(arcs, arc)
+	}
+	return arcs, nil
+}
+
+// formatDottedArcs returns the dotted string of the arcs.
+func formatDottedArcs(arcs []uint64) string {
+	parts := make([]string, len(arcs))
+	for i, arc := range arcs {
+		parts[i] = strconv.FormatUint(arc, 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// characterStringType is the ASN.1 restricted character string type of a string field.
+type characterStringType int
+
+const (
+	stringTypeOctet     characterStringType = iota // untagged string, encoded as an OCTET STRING
+	stringTypeNumeric                              // NumericString
+	stringTypePrintable                            // PrintableString
+	stringTypeIA5                                  // IA5String
+	stringTypeVisible                              // VisibleString
+	stringTypeUTF8                                 // UTF8String
+	stringTypeBMP                                  // BMPString
+	stringTypeUniversal                            // UniversalString
+)
+
+// String returns the ASN.1 name of the character string type.
+func (t characterStringType) String() string {
+	switch t {
+	case stringTypeNumeric:
+		return "NumericString"
+	case stringTypePrintable:
+		return "PrintableString"
+	case stringTypeIA5:
+		return "IA5String"
+	case stringTypeVisible:
+		return "VisibleString"
+	case stringTypeUTF8:
+		return "UTF8String"
+	case stringTypeBMP:
+		return "BMPString"
+	case stringTypeUniversal:
+		return "UniversalString"
+	}
+	return "OCTET STRING"
+}
+
+// isKnownMultiplier reports whether the character string type is encoded with a
+// fixed number of bits per character (X.691 30).
+func (t characterStringType) isKnownMultiplier() bool {
+	return t != stringTypeOctet && t != stringTypeUTF8
+}
+
+// checkUTF8StringSize checks the number of characters of a UTF8String against
+// its size constraint, which is not PER-visible and so does not affect the encoding.
+func checkUTF8StringSize(value string, params fieldParameters) error {
+	if params.sizeExtensible {
+		return nil
+	}
+	length := int64(utf8.RuneCountInString(value))
+	if params.sizeLowerBound != nil && length < *params.sizeLowerBound {
+		return fmt.Errorf("UTF8String length(%d) is under lowerbound(%d)", length, *params.sizeLowerBound)
+	} else if params.sizeUpperBound != nil && length > *params.sizeUpperBound {
+		return fmt.Errorf("UTF8String length(%d) is over upperbound(%d)", length, *params.sizeUpperBound)
+	}
+	return nil
+}
+
+// characterEncoding is how each character of a known-multiplier character string
+// is encoded in the ALIGNED variant (X.691 30.5).
+type characterEncoding struct {
+	bits     uint   // the number of bits of each character.
+	alphabet []rune // the effective permitted alphabet in canonical order (nil if every value up to maxValue).
+	maxValue uint64 // the largest character value of the effective permitted alphabet.
+	indexed  bool   // true iff a character is encoded as its index in the alphabet rather than its value.
+}
+
+// getCharacterEncoding returns the characterEncoding of the character string type
+// with the permitted alphabet (if not empty) applied.
+func getCharacterEncoding(stringType characterStringType, permittedAlphabet string) (characterEncoding, error) {
+	var ce characterEncoding
+	switch stringType {
+	case stringTypeNumeric:
+		ce.alphabet = []rune(" 0123456789")
+	case stringTypePrintable:
+		ce.alphabet = []rune(" '()+,-./0123456789:=?ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+	case stringTypeIA5:
+		ce.maxValue = 0x7f
+	case stringTypeVisible:
+		for c := rune(0x20); c <= 0x7e; c++ {
+			ce.alphabet = append(ce.alphabet, c)
+		}
+	case stringTypeBMP:
+		ce.maxValue = 0xffff
+	case stringTypeUniversal:
+		ce.maxValue = 0xffffffff
+	default:
+		return ce, fmt.Errorf("%s is not a known-multiplier character string type", stringType)
+	}
+	if ce.alphabet != nil {
+		ce.maxValue = uint64(ce.alphabet[len(ce.alphabet)-1])
+	}
+
+	if permittedAlphabet != "" {
+		var alphabet []rune
+		for _, c := range permittedAlphabet {
+			if !ce.contains(c) {
+				return ce, fmt.Errorf("permitted alphabet character %q is not a %s character", c, stringType)
+			}
+			alphabet = append(alphabet, c)
+		}
+		sort.Slice(alphabet, func(i, j int) bool { return alphabet[i] < alphabet[j] })
+		ce.alphabet = alphabet[:0]
+		for i, c := range alphabet {
+			if i == 0 || c != alphabet[i-1] {
+				ce.alphabet = append(ce.alphabet, c)
+			}
+		}
+		ce.maxValue = uint64(ce.alphabet[len(ce.alphabet)-1])
+	}
+
+	numChars := ce.maxValue + 1
+	if ce.alphabet != nil {
+		numChars = uint64(len(ce.alphabet))
+	}
+	var b uint
+	for ; b < 64 && uint64(1)<<b < numChars; b++ {
+	}
+	// the ALIGNED variant rounds up to a power of 2
+	for ce.bits = 1; ce.bits < b; ce.bits <<= 1 {
+	}
+	ce.indexed = ce.bits < 64 && ce.maxValue > uint64(1)<<ce.bits-1
+	return ce, nil
+}
+
+// contains reports whether c is in the effective permitted alphabet.
+func (ce characterEncoding) contains(c rune) bool {
+	if c < 0 || uint64(c) > ce.maxValue {
+		return false
+	} else if ce.alphabet == nil {
+		return true
+	}
+	i := sort.Search(len(ce.alphabet), func(i int) bool { return ce.alphabet[i] >= c })
+	return i < len(ce.alphabet) && ce.alphabet[i] == c
+}
+
+// encode returns the value which represents the character c.
+func (ce characterEncoding) encode(c rune) (uint64, error) {
+	if !ce.contains(c) {
+		return 0, fmt.Errorf("character %q is not in the permitted alphabet", c)
+	} else if ce.indexed {
+		return uint64(sort.Search(len(ce.alphabet), func(i int) bool { return ce.alphabet[i] >= c })), nil
+	}
+	return uint64(c), nil
+}
+
+// decode returns the character which is represented by the value.
+func (ce characterEncoding) decode(value uint64) (rune, error) {
+	if ce.indexed {
+		if value >= uint64(len(ce.alphabet)) {
+			return 0, fmt.Errorf("character index %d is out of the permitted alphabet", value)
+		}
+		return ce.alphabet[value], nil
+	} else if value > math.MaxInt32 || !utf8.ValidRune(rune(value)) {
+		return 0, fmt.Errorf("character value 0x%x is not a Unicode scalar value", value)
+	} else if !ce.contains(rune(value)) {
+		return 0, fmt.Errorf("character value 0x%x is not in the permitted alphabet", value)
+	}
+	return rune(value), nil
+}
+
+// timeFormat is the ASN.1 useful time type of a time.Time field.
+type timeFormat int
+
+const (
+	timeFormatGeneralized timeFormat = iota // GeneralizedTime
+	timeFormatUTC                           // UTCTime
+)
+
+// String returns the ASN.1 name of the time type.
+func (f timeFormat) String() string {
+	if f == timeFormatUTC {
+		return "UTCTime"
+	}
+	return "GeneralizedTime"
+}
+
+// formatTime returns the canonical form of t (X.691 11.17 and X.690 11.7, 11.8): the UTC time with
+// seconds, a trailing "Z" and, for a GeneralizedTime, fractional seconds without trailing zeros.
+func formatTime(t time.Time, format timeFormat) (string, error) {
+	t = t.UTC()
+	if format == timeFormatUTC {
+		if t.Year() < 1950 || t.Year() > 2049 {
+			return "", fmt.Errorf("year %d is out of the UTCTime range 1950..2049", t.Year())
+		}
+		return t.Format("060102150405Z"), nil
+	}
+	if t.Year() < 0 || t.Year() > 9999 {
+		return "", fmt.Errorf("year %d is out of the GeneralizedTime range 0..9999", t.Year())
+	}
+	return t.Format("20060102150405.999999999") + "Z", nil
+}
+
+// parseTime parses a GeneralizedTime (YYYYMMDDHH[MM[SS]][.fraction][zone]) or
+// UTCTime (YYMMDDhhmm[ss]zone) where zone is "Z" or a "+hhmm"/"-hhmm" offset
+// from UTC. A GeneralizedTime without zone is a local time of an unknown zone,
+// which is taken as UTC so that the result does not depend on the host.
+func parseTime(s string, format timeFormat) (time.Time, error) {
+	value, loc := s, time.UTC
+	if strings.HasSuffix(value, "Z") {
+		value, loc = value[:len(value)-1], time.UTC
+	} else if i := strings.LastIndexAny(value, "+-"); i >= 0 {
+		offset := value[i+1:]
+		if len(offset) != 2 && len(offset) != 4 {
+			return time.Time{}, fmt.Errorf("invalid %s %q: malformed time zone", format, s)
+		}
+		hours, err1 := strconv.Atoi(offset[:2])
+		minutes, err2 := 0, error(nil)
+		if len(offset) == 4 {
+			minutes, err2 = strconv.Atoi(offset[2:])
+		}
+		if err1 != nil || err2 != nil || hours > 23 || minutes > 59 {
+			return time.Time{}, fmt.Errorf("invalid %s %q: malformed time zone", format, s)
+		}
+		seconds := hours*3600 + minutes*60
+		if value[i] == '-' {
+			seconds = -seconds
+		}
+		value, loc = value[:i], time.FixedZone(value[i:], seconds)
+	} else if format == timeFormatUTC {
+		return time.Time{}, fmt.Errorf("invalid %s %q: missing time zone", format, s)
+	}
+
+	if format == timeFormatUTC {
+		layout := "0601021504"
+		if len(value) == 12 {
+			layout += "05"
+		}
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s %q: %v", format, s, err)
+		}
+		// YY is 1950..2049 rather than the 1969..2068 of time.Parse
+		if t.Year() >= 2050 {
+			t = time.Date(t.Year()-100, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		return t, nil
+	}
+
+	var fraction string
+	if i := strings.IndexAny(value, ".,"); i >= 0 {
+		value, fraction = value[:i], value[i+1:]
+		if fraction == "" || strings.Trim(fraction, "0123456789") != "" {
+			return time.Time{}, fmt.Errorf("invalid %s %q: malformed fraction", format, s)
+		}
+	}
+	var layout string
+	var unit time.Duration
+	switch len(value) {
+	case 10:
+		layout, unit = "2006010215", time.Hour
+	case 12:
+		layout, unit = "200601021504", time.Minute
+	case 14:
+		layout, unit = "20060102150405", time.Second
+	default:
+		return time.Time{}, fmt.Errorf("invalid %s %q: malformed date and time", format, s)
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %v", format, s, err)
+	}
+	scale := time.Duration(1)
+	for _, digit := range fraction {
+		if scale *= 10; unit/scale == 0 {
+			break
+		}
+		t = t.Add(time.Duration(digit-'0') * unit / scale)
+	}
+	return t, nil
+}
+
+// tagClass is the class of an ASN.1 tag. The classes are in canonical order (X.680 8.6).
+type tagClass int
+
+const (
+	tagClassUniversal tagClass = iota
+	tagClassApplication
+	tagClassContextSpecific
+	tagClassPrivate
+)
+
+// asnTag is an ASN.1 tag, which decides the canonical order of the components of a SET.
+type asnTag struct {
+	class  tagClass
+	number int64
+}
+
+// parseASNTag parses a tag of the form [class:]number, where class is universal,
+// application, context (the default) or private.
+func parseASNTag(str string) (*asnTag, error) {
+	tag := &asnTag{class: tagClassContextSpecific}
+	if i := strings.LastIndex(str, ":"); i >= 0 {
+		switch str[:i] {
+		case "universal":
+			tag.class = tagClassUniversal
+		case "application":
+			tag.class = tagClassApplication
+		case "context":
+			tag.class = tagClassContextSpecific
+		case "private":
+			tag.class = tagClassPrivate
+		default:
+			return nil, fmt.Errorf("unknown tag class %q", str[:i])
+		}
+		str = str[i+1:]
+	}
+	number, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || number < 0 {
+		return nil, fmt.Errorf("invalid tag number %q", str)
+	}
+	tag.number = number
+	return tag, nil
+}
+
+// less reports whether the tag t precedes the tag u in canonical order.
+func (t asnTag) less(u asnTag) bool {
+	if t.class != u.class {
+		return t.class < u.class
+	}
+	return t.number < u.number
+}
+
+// getCanonicalTag returns the tag which decides the canonical order of a component of
+// type t: the tag given by the tag parameter, or else the universal tag of the type. An
+// untagged CHOICE has the smallest tag of its alternatives (X.680 8.6).
+func getCanonicalTag(t reflect.Type, params fieldParameters) (asnTag, error) {
+	if params.tag != nil {
+		return *params.tag, nil
+	} else if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	universal := func(number int64) (asnTag, error) {
+		return asnTag{class: tagClassUniversal, number: number}, nil
+	}
+
+	switch t {
+	case BitStringType:
+		return universal(3)
+	case OctetStringType:
+		return universal(4)
+	case NullType:
+		return universal(5)
+	case ObjectIdentifierType:
+		return universal(6)
+	case EnumeratedType:
+		return universal(10)
+	case RelativeOIDType:
+		return universal(13)
+	case TimeType:
+		return universal(14)
+	case DateType:
+		return universal(31)
+	case reflect.TypeOf(TimeOfDay{}):
+		return universal(32)
+	case reflect.TypeOf(DateTime{}):
+		return universal(33)
+	case reflect.TypeOf(Duration{}):
+		return universal(34)
+	case goTimeType:
+		if params.timeFormat == timeFormatUTC {
+			return universal(23)
+		}
+		return universal(24)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return universal(1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return universal(2)
+	case reflect.Float32, reflect.Float64:
+		return universal(9)
+	case reflect.String:
+		return universal(map[characterStringType]int64{
+			stringTypeOctet:     4,
+			stringTypeUTF8:      12,
+			stringTypeNumeric:   18,
+			stringTypePrintable: 19,
+			stringTypeIA5:       22,
+			stringTypeVisible:   26,
+			stringTypeUniversal: 28,
+			stringTypeBMP:       30,
+		}[params.stringType])
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return universal(4)
+		} else if params.set {
+			return universal(17)
+		}
+		return universal(16)
+	case reflect.Struct:
+		if t.NumField() == 0 || t.Field(0).Name != PRESENT {
+			if params.set {
+				return universal(17)
+			}
+			return universal(16)
+		}
+		var tag *asnTag
+		for i := 1; i < t.NumField(); i++ {
+			if t.Field(i).Type == RawExtensionsType || t.Field(i).Type == UnknownOpenTypeType {
+				continue
+			}
+			alternativeTag, err := getCanonicalTag(t.Field(i).Type, parseFieldParameters(t.Field(i).Tag.Get("aper")))
+			if err != nil {
+				return asnTag{}, err
+			} else if tag == nil || alternativeTag.less(*tag) {
+				tag = &alternativeTag
+			}
+		}
+		if tag != nil {
+			return *tag, nil
+		}
+	}
+	return asnTag{}, fmt.Errorf("%s has no tag for the canonical order of SET", t)
+}
+
+// sortSetComponents sorts the indexes of the root components of the SET structType into
+// canonical tag order, which is the order of their encodings (X.691 21.1).
+func sortSetComponents(structType reflect.Type, fields []reflect.StructField, structParams []fieldParameters,
+	indexes []int,
+) error {
+	tags := make(map[int]asnTag, len(indexes))
+	for _, i := range indexes {
+		tag, err := getCanonicalTag(fields[i].Type, structParams[i])
+		if err != nil {
+			return fmt.Errorf("field \"%s\" of SET %s: %v", fields[i].Name, structType, err)
+		}
+		tags[i] = tag
+	}
+	sort.SliceStable(indexes, func(a, b int) bool { return tags[indexes[a]].less(tags[indexes[b]]) })
+	for k := 1; k < len(indexes); k++ {
+		if !tags[indexes[k-1]].less(tags[indexes[k]]) {
+			return fmt.Errorf("fields \"%s\" and \"%s\" of SET %s have the same tag", fields[indexes[k-1]].Name,
+				fields[indexes[k]].Name, structType)
+		}
+	}
+	return nil
+}
+
+// appendSignedBigInt appends the minimal two's-complement form of x to b.
+func appendSignedBigInt(b []byte, x *big.Int) []byte {
+	if x.Sign() >= 0 {
+		contents := x.Bytes()
+		if len(contents) == 0 || contents[0]&0x80 != 0 {
+			b = append(b, 0x00)
+		}
+		return append(b, contents...)
+	}
+	// the two's complement of x is the complement of -x-1
+	contents := new(big.Int).Not(x).Bytes()
+	for i := range contents {
+		contents[i] = ^contents[i]
+	}
+	if len(contents) == 0 || contents[0]&0x80 == 0 {
+		b = append(b, 0xff)
+	}
+	return append(b, contents...)
+}
+
+// parseSignedBigInt parses the two's-complement form b.
+func parseSignedBigInt(b []byte) *big.Int {
+	x := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return x
+}
+
+// bigIntOf returns the *big.Int of the big.Int value v.
+func bigIntOf(v reflect.Value) *big.Int {
+	if v.CanAddr() {
+		return v.Addr().Interface().(*big.Int)
+	}
+	x := v.Interface().(big.Int)
+	return &x
+}
+
+// openTypeObject is the Go type, and its parameters, of the open type of an object
+// in an information object set.
+type openTypeObject struct {
+	valueType reflect.Type
+	tag       string
+	params    fieldParameters
+}
+
+var (
+	objectSetsMutex sync.RWMutex
+	objectSets      = make(map[string]map[int64]openTypeObject)
+)
+
+// RegisterOpenType registers the type of value as the open type of the object with
+// the reference value referenceValue in the information object set objectSet, such as
+// NGAP-PROTOCOL-IES id 85 to *RANUENGAPID, with params in the format of the struct
+// tags. An open type field of an interface type refers to the object set with the
+// objectSet tag, or else with the package-qualified name of the interface type, such
+// as "example.com/ngap.ProtocolIEValue", and is decoded into the registered type of
+// its reference value. A value which is not registered is decoded as UnknownOpenType
+// if the interface allows it. A reference value may be registered again only with
+// the same type and params.
+func RegisterOpenType(objectSet string, referenceValue int64, value interface{}, params string) error {
+	if objectSet == "" {
+		return fmt.Errorf("object set name is empty")
+	} else if value == nil {
+		return fmt.Errorf("open type of reference value %d in %s is nil", referenceValue, objectSet)
+	}
+	valueType := reflect.TypeOf(value)
+	objectSetsMutex.Lock()
+	defer objectSetsMutex.Unlock()
+	objects, ok := objectSets[objectSet]
+	if !ok {
+		objects = make(map[int64]openTypeObject)
+		objectSets[objectSet] = objects
+	}
+	if object, ok := objects[referenceValue]; ok && object.valueType != valueType {
+		return fmt.Errorf("reference value %d in %s is already registered to %s", referenceValue, objectSet,
+			object.valueType)
+	} else if ok && object.tag != params {
+		return fmt.Errorf("reference value %d in %s is already registered with params %q", referenceValue,
+			objectSet, object.tag)
+	}
+	objects[referenceValue] = openTypeObject{valueType, params, parseFieldParameters(params)}
+	return nil
+}
+
+// getOpenTypeObject returns the object of the open type field of interface type t,
+// whose object set is given by its objectSet tag or by the package-qualified name of t.
+// The object is not found (ok is false) if the reference value is not registered in the
+// object set.
+func getOpenTypeObject(t reflect.Type, params fieldParameters) (object openTypeObject, ok bool, err error) {
+	objectSet := params.objectSet
+	if objectSet == "" && t.Name() != "" {
+		objectSet = t.PkgPath() + "." + t.Name()
+	}
+	if objectSet == "" {
+		return object, false, fmt.Errorf("open type of %s has no object set", t)
+	} else if params.globalReference {
+		return object, false, nil
+	} else if params.referenceFieldValue == nil {
+		return object, false, fmt.Errorf("openType reference value is empty")
+	}
+	objectSetsMutex.RLock()
+	defer objectSetsMutex.RUnlock()
+	objects, found := objectSets[objectSet]
+	if !found {
+		return object, false, fmt.Errorf("object set %s is not registered", objectSet)
+	}
+	object, ok = objects[*params.referenceFieldValue]
+	return object, ok, nil
+}
@@ -5,14 +5,21 @@
 package aper
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"math"
+	"math/big"
 	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
 )
 
 type perRawBitData struct {
 	bytes      []byte
 	bitsOffset uint
+	ancestors  []sequenceAncestor // the enclosing SEQUENCE and SET values, innermost last.
 }
 
 func perRawBitLog(numBits uint64, byteLen int, bitsOffset uint, value interface{}) string {
@@ -142,6 +149,26 @@ func (pd *perRawBitData) appendNormallySmallNonNegativeValue(value uint64) error
 	return err
 }
 
+func (pd *perRawBitData) appendNormallySmallLength(value uint64) error {
+	var err error
+	perTrace(3, fmt.Sprintf("Putting Normally Small Length %d", value))
+
+	if value == 0 {
+		return fmt.Errorf("normally small length is zero")
+	} else if value <= 64 {
+		if err = pd.putBitsValue(0, 1); err != nil {
+			return err
+		}
+		err = pd.putBitsValue(value-1, 6)
+	} else {
+		if err = pd.putBitsValue(1, 1); err != nil {
+			return err
+		}
+		err = pd.appendLength(-1, value)
+	}
+	return err
+}
+
 func (pd *perRawBitData) putSemiConstrainedWholeNumber(value uint64, lb uint64) error {
 	if lb > value {
 		return fmt.Errorf("value (%d) is less than lower bound value (%d)", value, lb)
@@ -237,41 +264,44 @@ func (pd *perRawBitData) appendBitString(bytes []byte, bitsLength uint64, extens
 		perTrace(2, fmt.Sprintf("Encoded BIT STRING (length = %d): 0x%0x", bitsLength, bytes))
 		return err
 	}
-	rawLength := bitsLength - uint64(lb)
-
-	var byteOffset, partOfRawLength uint64
-	for {
-		if rawLength > 65536 {
-			partOfRawLength = 65536
-		} else if rawLength >= 16384 {
-			partOfRawLength = rawLength & 0xc000
-		} else {
-			partOfRawLength = rawLength
-		}
-		if err = pd.appendLength(sizeRange, partOfRawLength); err != nil {
+	if sizeRange > 0 {
+		// a constrained length is never fragmented
+		if err = pd.appendLength(sizeRange, bitsLength-uint64(lb)); err != nil {
 			return err
 		}
-		partOfRawLength += uint64(lb)
-		sizes := (partOfRawLength + 7) >> 3
-		perTrace(2, fmt.Sprintf("Encoding BIT STRING size %d", partOfRawLength))
-		if partOfRawLength == 0 {
+		perTrace(2, fmt.Sprintf("Encoding BIT STRING size %d", bitsLength))
+		pd.appendBitStringFragment(bytes, bitsLength)
+		return nil
+	}
+
+	// X.691 11.9.3.8: fragments of 16K, 32K, 48K or 64K bits are followed by the
+	// length of the rest, which is zero if there is nothing left
+	for {
+		partLength := fragmentSize(bitsLength)
+		if err = pd.appendLength(-1, partLength); err != nil {
 			return err
 		}
-		pd.appendAlignBits()
-		pd.bytes = append(pd.bytes, bytes[byteOffset:byteOffset+sizes]...)
-		perTrace(1, perRawBitLog(partOfRawLength, len(pd.bytes), pd.bitsOffset, bytes))
-		perTrace(2, fmt.Sprintf("Encoded BIT STRING (length = %d): 0x%0x", partOfRawLength,
-			bytes[byteOffset:byteOffset+sizes]))
-		rawLength -= (partOfRawLength - uint64(lb))
-		if rawLength > 0 {
-			byteOffset += sizes
-		} else {
-			pd.bitsOffset += uint(partOfRawLength & 0x7)
-			// pd.appendAlignBits()
-			break
+		perTrace(2, fmt.Sprintf("Encoding BIT STRING size %d", partLength))
+		pd.appendBitStringFragment(bytes, partLength)
+		bytes = bytes[partLength>>3:]
+		bitsLength -= partLength
+		if partLength < 16384 {
+			return nil
 		}
 	}
-	return err
+}
+
+// appendBitStringFragment appends the first numBits bits of bytes, octet-aligned.
+func (pd *perRawBitData) appendBitStringFragment(bytes []byte, numBits uint64) {
+	if numBits == 0 {
+		return
+	}
+	sizes := (numBits + 7) >> 3
+	pd.appendAlignBits()
+	pd.bytes = append(pd.bytes, bytes[:sizes]...)
+	pd.bitsOffset = uint(numBits & 0x7)
+	perTrace(1, perRawBitLog(numBits, len(pd.bytes), pd.bitsOffset, bytes[:sizes]))
+	perTrace(2, fmt.Sprintf("Encoded BIT STRING (length = %d): 0x%0x", numBits, bytes[:sizes]))
 }
 
 func (pd *perRawBitData) appendOctetString(bytes []byte, extensive bool, lowerBoundPtr *int64,
@@ -324,39 +354,141 @@ func (pd *perRawBitData) appendOctetString(bytes []byte, extensive bool, lowerBo
 		perTrace(2, fmt.Sprintf("Encoded OCTET STRING (length = %d): 0x%0x", byteLen, bytes))
 		return nil
 	}
-	rawLength := byteLen - uint64(lb)
+	if sizeRange > 0 {
+		// a constrained length is never fragmented
+		if err := pd.appendLength(sizeRange, byteLen-uint64(lb)); err != nil {
+			return err
+		}
+		perTrace(2, fmt.Sprintf("Encoding OCTET STRING size %d", byteLen))
+		pd.appendOctetStringFragment(bytes)
+		return nil
+	}
 
-	var byteOffset, partOfRawLength uint64
+	// X.691 11.9.3.8: fragments of 16K, 32K, 48K or 64K octets are followed by the
+	// length of the rest, which is zero if there is nothing left
 	for {
-		if rawLength > 65536 {
-			partOfRawLength = 65536
-		} else if rawLength >= 16384 {
-			partOfRawLength = rawLength & 0xc000
+		partLength := fragmentSize(uint64(len(bytes)))
+		if err := pd.appendLength(-1, partLength); err != nil {
+			return err
+		}
+		perTrace(2, fmt.Sprintf("Encoding OCTET STRING size %d", partLength))
+		pd.appendOctetStringFragment(bytes[:partLength])
+		bytes = bytes[partLength:]
+		if partLength < 16384 {
+			return nil
+		}
+	}
+}
+
+// appendOctetStringFragment appends the octets of bytes, octet-aligned.
+func (pd *perRawBitData) appendOctetStringFragment(bytes []byte) {
+	if len(bytes) == 0 {
+		return
+	}
+	pd.appendAlignBits()
+	pd.bytes = append(pd.bytes, bytes...)
+	perTrace(1, perRawBitLog(uint64(len(bytes))*8, len(pd.bytes), pd.bitsOffset, bytes))
+	perTrace(2, fmt.Sprintf("Encoded OCTET STRING (length = %d): 0x%0x", len(bytes), bytes))
+}
+
+// fragmentSize returns the number of units in the next fragment of a length
+// determinant when remaining units are left to encode (X.691 11.9.3.8).
+func fragmentSize(remaining uint64) uint64 {
+	if remaining < 16384 {
+		return remaining
+	}
+	return min(remaining/16384, 4) * 16384
+}
+
+func (pd *perRawBitData) appendCharacterString(value string, params fieldParameters) error {
+	ce, err := getCharacterEncoding(params.stringType, params.permittedAlphabet)
+	if err != nil {
+		return err
+	} else if !utf8.ValidString(value) {
+		return fmt.Errorf("%s %q is not valid UTF-8", params.stringType, value)
+	}
+	chars := []rune(value)
+	length := uint64(len(chars))
+	var lb, ub int64 = 0, -1
+	if params.sizeLowerBound != nil {
+		lb = *params.sizeLowerBound
+	}
+	if params.sizeUpperBound != nil {
+		ub = *params.sizeUpperBound
+	}
+	inRoot := length >= uint64(lb) && (ub == -1 || length <= uint64(ub))
+	if params.sizeExtensible {
+		perTrace(2, "Putting size Extension Value")
+		if inRoot {
+			err = pd.putBitsValue(0, 1)
 		} else {
-			partOfRawLength = rawLength
+			err = pd.putBitsValue(1, 1)
+			lb, ub = 0, -1
 		}
-		if err := pd.appendLength(sizeRange, partOfRawLength); err != nil {
+		if err != nil {
 			return err
 		}
-		partOfRawLength += uint64(lb)
-		perTrace(2, fmt.Sprintf("Encoding OCTET STRING size %d", partOfRawLength))
-		if partOfRawLength == 0 {
+	} else if !inRoot {
+		return fmt.Errorf("%s length(%d) is out of constraint(%d..%d)", params.stringType, length, lb, ub)
+	}
+
+	codes := make([]uint64, length)
+	for i, c := range chars {
+		if codes[i], err = ce.encode(c); err != nil {
+			return fmt.Errorf("encode %s: %v", params.stringType, err)
+		}
+	}
+	// X.691 30.5.7: small strings are not octet-aligned
+	aligned := ub == -1 || uint64(ub)*uint64(ce.bits) > 16
+	perTrace(2, fmt.Sprintf("Encoding %s \"%s\" with %d bits per character", params.stringType, value, ce.bits))
+
+	putChars := func(codes []uint64) error {
+		if len(codes) == 0 {
 			return nil
+		} else if aligned {
+			pd.appendAlignBits()
 		}
-		pd.appendAlignBits()
-		pd.bytes = append(pd.bytes, bytes[byteOffset:byteOffset+partOfRawLength]...)
-		perTrace(1, perRawBitLog(partOfRawLength*8, len(pd.bytes), pd.bitsOffset, bytes))
-		perTrace(2, fmt.Sprintf("Encoded OCTET STRING (length = %d): 0x%0x", partOfRawLength,
-			bytes[byteOffset:byteOffset+partOfRawLength]))
-		rawLength -= (partOfRawLength - uint64(lb))
-		if rawLength > 0 {
-			byteOffset += partOfRawLength
-		} else {
-			// pd.appendAlignBits()
-			break
+		for _, code := range codes {
+			if err := pd.putBitsValue(code, ce.bits); err != nil {
+				return err
+			}
 		}
+		return nil
 	}
-	return nil
+
+	if ub != -1 && ub < 65536 {
+		if lb != ub {
+			if err := pd.appendConstraintValue(ub-lb+1, length-uint64(lb)); err != nil {
+				return err
+			}
+		}
+		return putChars(codes)
+	}
+
+	for {
+		partLength := fragmentSize(uint64(len(codes)))
+		if err := pd.appendLength(-1, partLength); err != nil {
+			return err
+		}
+		if err := putChars(codes[:partLength]); err != nil {
+			return err
+		}
+		codes = codes[partLength:]
+		// a final fragment of a multiple of 16K characters is followed by a zero length
+		if partLength < 16384 {
+			return nil
+		}
+	}
+}
+
+func (pd *perRawBitData) appendUTF8String(value string, params fieldParameters) error {
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("UTF8String %q is not valid UTF-8", value)
+	} else if err := checkUTF8StringSize(value, params); err != nil {
+		return err
+	}
+	perTrace(2, fmt.Sprintf("Encoding UTF8String \"%s\"", value))
+	return pd.appendOctetString([]byte(value), false, nil, nil)
 }
 
 func (pd *perRawBitData) appendBool(value bool) error {
@@ -420,8 +552,11 @@ func (pd *perRawBitData) appendInteger(value int64, extensive bool, lowerBoundPt
 		y := value >> 63
 		unsignedValue = uint64(((value ^ y) - y)) - 1
 	}
-	if valueRange <= 0 {
+	if valueRange < 0 {
 		unsignedValue >>= 7
+	} else if valueRange == 0 {
+		// a semi-constrained whole number is the non-negative offset from lb
+		unsignedValue = uint64(value-lb) >> 8
 	} else if valueRange <= 65536 {
 		return pd.appendConstraintValue(valueRange, uint64(value-lb))
 	} else {
@@ -447,7 +582,7 @@ func (pd *perRawBitData) appendInteger(value int64, extensive bool, lowerBoundPt
 		unsignedValueRange := uint64(valueRange - 1)
 		for byteLen = 1; byteLen <= 127; byteLen++ {
 			unsignedValueRange >>= 8
-			if unsignedValueRange <= 1 {
+			if unsignedValueRange == 0 {
 				break
 			}
 		}
@@ -478,6 +613,114 @@ func (pd *perRawBitData) appendInteger(value int64, extensive bool, lowerBoundPt
 	}
 }
 
+// appendBigInteger encodes an INTEGER of any size as a constrained, semi-constrained or
+// unconstrained whole number (X.691 13).
+func (pd *perRawBitData) appendBigInteger(value *big.Int, extensive bool, lb *big.Int, ub *big.Int) error {
+	if lb != nil && ub != nil {
+		inRoot := value.Cmp(lb) >= 0 && value.Cmp(ub) <= 0
+		if extensive {
+			perTrace(2, "Putting value Extension bit")
+			var bit uint64
+			if !inRoot {
+				bit = 1
+			}
+			if err := pd.putBitsValue(bit, 1); err != nil {
+				return err
+			}
+		}
+		if !inRoot && !extensive {
+			return fmt.Errorf("integer value %s is out of range(%s..%s)", value, lb, ub)
+		} else if inRoot {
+			valueRange := new(big.Int).Sub(ub, lb)
+			valueRange.Add(valueRange, big.NewInt(1))
+			offset := new(big.Int).Sub(value, lb)
+			perTrace(3, fmt.Sprintf("Encoding INTEGER %s with Value Range(%s..%s)", value, lb, ub))
+			if valueRange.Cmp(big.NewInt(65536)) <= 0 {
+				return pd.appendConstraintValue(valueRange.Int64(), offset.Uint64())
+			}
+			// the length of the octets is a constrained whole number in 1..(octets of ub-lb)
+			maxLength := int64(len(valueRange.Sub(valueRange, big.NewInt(1)).Bytes()))
+			contents := offset.Bytes()
+			if len(contents) == 0 {
+				contents = []byte{0}
+			}
+			if err := pd.appendConstraintValue(maxLength, uint64(len(contents)-1)); err != nil {
+				return err
+			}
+			pd.appendAlignBits()
+			return pd.putBitString(contents, uint(len(contents))*8)
+		}
+		lb = nil
+	}
+
+	var contents []byte
+	if lb != nil {
+		if value.Cmp(lb) < 0 {
+			return fmt.Errorf("integer value %s is smaller than lowerbound %s", value, lb)
+		}
+		perTrace(3, fmt.Sprintf("Encoding INTEGER %s with Semi-Constraint Range(%s..)", value, lb))
+		contents = new(big.Int).Sub(value, lb).Bytes()
+		if len(contents) == 0 {
+			contents = []byte{0}
+		}
+	} else {
+		perTrace(3, fmt.Sprintf("Encoding INTEGER %s with Unconstraint Value", value))
+		contents = appendSignedBigInt(nil, value)
+	}
+	if len(contents) >= 16384 {
+		return fmt.Errorf("integer value of %d octets is too large", len(contents))
+	} else if err := pd.appendLength(-1, uint64(len(contents))); err != nil {
+		return err
+	}
+	return pd.putBitString(contents, uint(len(contents))*8)
+}
+
+// appendReal encodes value as the length-prefixed CER contents octets of a REAL
+// (X.691 15, X.690 8.5 and 11.3), using base 2 for finite non-zero values.
+func (pd *perRawBitData) appendReal(value float64) error {
+	var contents []byte
+	switch {
+	case math.IsInf(value, 1):
+		contents = []byte{0x40}
+	case math.IsInf(value, -1):
+		contents = []byte{0x41}
+	case math.IsNaN(value):
+		contents = []byte{0x42}
+	case value == 0 && math.Signbit(value):
+		contents = []byte{0x43}
+	case value == 0:
+		contents = []byte{}
+	default:
+		firstOctet := byte(0x80)
+		if value < 0 {
+			firstOctet |= 0x40
+			value = -value
+		}
+		frac, exponent := math.Frexp(value)
+		mantissa := uint64(frac * (1 << 53))
+		exponent -= 53
+		// the mantissa shall be zero or odd
+		for mantissa&1 == 0 {
+			mantissa >>= 1
+			exponent++
+		}
+		exponentOctets := []byte{byte(exponent)}
+		for e := exponent; e > 127 || e < -128; {
+			e >>= 8
+			exponentOctets = append([]byte{byte(e)}, exponentOctets...)
+		}
+		firstOctet |= byte(len(exponentOctets) - 1)
+		contents = append([]byte{firstOctet}, exponentOctets...)
+		mantissaOctets := []byte{}
+		for ; mantissa > 0; mantissa >>= 8 {
+			mantissaOctets = append([]byte{byte(mantissa)}, mantissaOctets...)
+		}
+		contents = append(contents, mantissaOctets...)
+	}
+	perTrace(2, fmt.Sprintf("Encoding REAL contents octets : 0x%0x", contents))
+	return pd.appendOctetString(contents, false, nil, nil)
+}
+
 func (pd *perRawBitData) appendEnumerated(value uint64, extensive bool, lowerBoundPtr *int64,
 	upperBoundPtr *int64,
 ) error {
@@ -519,31 +762,29 @@ func (pd *perRawBitData) parseSequenceOf(v reflect.Value, params fieldParameters
 	if params.sizeLowerBound != nil && *params.sizeLowerBound < 65536 {
 		lb = *params.sizeLowerBound
 	}
-	if params.sizeUpperBound != nil && *params.sizeUpperBound < 65536 {
-		ub = *params.sizeUpperBound
-		if params.sizeExtensible {
-			if numElements > ub {
-				if err := pd.putBitsValue(1, 1); err != nil {
-					return err
-				}
-			} else {
-				if err := pd.putBitsValue(0, 1); err != nil {
-					return err
-				}
-				sizeRange = ub - lb + 1
-			}
-		} else if numElements > ub {
-			return fmt.Errorf("sequence of size is larger than upperbound")
-		} else {
-			sizeRange = ub - lb + 1
+	inRoot := numElements >= lb && (params.sizeUpperBound == nil || numElements <= *params.sizeUpperBound)
+	if params.sizeExtensible {
+		var bit uint64
+		if !inRoot {
+			bit = 1
 		}
-	} else {
-		sizeRange = -1
+		if err := pd.putBitsValue(bit, 1); err != nil {
+			return err
+		}
+	} else if numElements < lb {
+		return fmt.Errorf("sequence of size is lower than lowerbound")
+	} else if !inRoot {
+		return fmt.Errorf("sequence of size is larger than upperbound")
+	}
+	if !inRoot {
+		// a size outside the extension root has a semi-constrained length (X.691 20.6)
+		lb = 0
+	} else if params.sizeUpperBound != nil && *params.sizeUpperBound < 65536 {
+		ub = *params.sizeUpperBound
+		sizeRange = ub - lb + 1
 	}
 
-	if numElements < lb {
-		return fmt.Errorf("sequence of size is lower than lowerbound")
-	} else if sizeRange == 1 {
+	if sizeRange == 1 {
 		perTrace(3, fmt.Sprintf("Encoding Length of \"SEQUENCE OF\"  with fix-size %d", ub))
 		if numElements != ub {
 			return fmt.Errorf("encoding length %d != fix-size %d", numElements, ub)
@@ -553,22 +794,64 @@ func (pd *perRawBitData) parseSequenceOf(v reflect.Value, params fieldParameters
 		if err := pd.appendConstraintValue(sizeRange, uint64(numElements-lb)); err != nil {
 			return err
 		}
-	} else {
-		perTrace(3, fmt.Sprintf("Encoding Length(%d) of \"SEQUENCE OF\" with Semi-Constraint Range(%d..)", numElements, lb))
-		pd.appendAlignBits()
-		pd.bytes = append(pd.bytes, byte(numElements&0xff))
-		perTrace(1, perRawBitLog(8, len(pd.bytes), pd.bitsOffset, uint64(numElements)))
 	}
 	perTrace(2, fmt.Sprintf("Encoding  \"SEQUENCE OF\" struct %s with len(%d)", v.Type().Elem().Name(), numElements))
-	params.sizeExtensible = false
-	params.sizeUpperBound = nil
-	params.sizeLowerBound = nil
-	for i := 0; i < v.Len(); i++ {
-		if err := pd.makeField(v.Index(i), params); err != nil {
+	isSet := params.set
+	params = getElementParameters(params)
+	if isSet {
+		sorted, err := pd.sortSetOf(v, params)
+		if err != nil {
 			return err
 		}
+		v = sorted
+	}
+
+	appendElements := func(elements reflect.Value) error {
+		for i := 0; i < elements.Len(); i++ {
+			if err := pd.makeField(elements.Index(i), params); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if sizeRange > 0 {
+		return appendElements(v)
+	}
+	// semi-constrained length, fragmented in units of 16K elements (X.691 11.9.3.8)
+	for {
+		partLength := fragmentSize(uint64(v.Len()))
+		perTrace(3, fmt.Sprintf("Encoding Length(%d) of \"SEQUENCE OF\" with Semi-Constraint Range(%d..)", partLength, lb))
+		if err := pd.appendLength(-1, partLength); err != nil {
+			return err
+		} else if err := appendElements(v.Slice(0, int(partLength))); err != nil {
+			return err
+		}
+		v = v.Slice(int(partLength), v.Len())
+		if partLength < 16384 {
+			return nil
+		}
 	}
-	return nil
+}
+
+// sortSetOf returns a copy of the SET OF v whose elements are sorted into ascending
+// order of their encodings, as required by CANONICAL-PER (X.691 22.1).
+func (pd *perRawBitData) sortSetOf(v reflect.Value, elemParams fieldParameters) (reflect.Value, error) {
+	encodings := make([][]byte, v.Len())
+	order := make([]int, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		pdElem := &perRawBitData{[]byte{}, 0, pd.ancestors}
+		if err := pdElem.makeField(v.Index(i), elemParams); err != nil {
+			return v, err
+		}
+		encodings[i] = pdElem.bytes
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return bytes.Compare(encodings[order[a]], encodings[order[b]]) < 0 })
+	sorted := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	for i, j := range order {
+		sorted.Index(i).Set(v.Index(j))
+	}
+	return sorted, nil
 }
 
 func (pd *perRawBitData) appendChoiceIndex(present int, extensive bool, upperBoundPtr *int64) error {
@@ -578,8 +861,20 @@ func (pd *perRawBitData) appendChoiceIndex(present int, extensive bool, upperBou
 		return fmt.Errorf("the upper bound of CHIOCE is missing")
 	} else if ub = *upperBoundPtr; ub < 0 {
 		return fmt.Errorf("the upper bound of CHIOCE is negative")
-	} else if extensive && rawChoice > int(ub) {
-		return fmt.Errorf("unsupport value of CHOICE type is in Extensed")
+	} else if rawChoice > int(ub) {
+		if !extensive {
+			return fmt.Errorf("present index of CHOICE is larger than upperbound")
+		}
+		perTrace(2, fmt.Sprintf("Encoding Present index of CHOICE %d - %d - 2", present, ub))
+		if err := pd.putBitsValue(1, 1); err != nil {
+			return err
+		}
+		return pd.appendNormallySmallNonNegativeValue(uint64(rawChoice) - uint64(ub) - 1)
+	}
+	if extensive {
+		if err := pd.putBitsValue(0, 1); err != nil {
+			return err
+		}
 	}
 	perTrace(2, fmt.Sprintf("Encoding Present index of CHOICE  %d - 1", present))
 	if err := pd.appendConstraintValue(ub+1, uint64(rawChoice)); err != nil {
@@ -588,48 +883,226 @@ func (pd *perRawBitData) appendChoiceIndex(present int, extensive bool, upperBou
 	return nil
 }
 
+// appendChoiceRawExtension re-encodes the unknown extension alternative of a CHOICE
+// kept in its RawExtensions field.
+func (pd *perRawBitData) appendChoiceRawExtension(rawExtensions RawExtensions, extensive bool,
+	upperBoundPtr *int64,
+) error {
+	if len(rawExtensions) != 1 {
+		return fmt.Errorf("raw extension of CHOICE must have exactly one alternative, got %d", len(rawExtensions))
+	} else if !extensive || upperBoundPtr == nil {
+		return fmt.Errorf("raw extension of CHOICE which is not extensible")
+	}
+	present := int(rawExtensions[0].Index) + int(*upperBoundPtr) + 2
+	if err := pd.appendChoiceIndex(present, extensive, upperBoundPtr); err != nil {
+		return err
+	}
+	return pd.appendOpenTypeContents(rawExtensions[0].Bytes)
+}
+
 func (pd *perRawBitData) appendOpenType(v reflect.Value, params fieldParameters) error {
-	pdOpenType := &perRawBitData{[]byte(""), 0}
+	pdOpenType := &perRawBitData{[]byte(""), 0, pd.ancestors}
 	perTrace(2, fmt.Sprintf("Encoding OpenType %s to temp RawData", v.Type().String()))
 	if err := pdOpenType.makeField(v, params); err != nil {
 		return err
 	}
-	openTypeBytes := pdOpenType.bytes
-	rawLength := uint64(len(pdOpenType.bytes))
 	perTrace(2, fmt.Sprintf("Encoding OpenType %s RawData : 0x%0x(%d bytes)", v.Type().String(), pdOpenType.bytes,
-		rawLength))
+		len(pdOpenType.bytes)))
+	if err := pd.appendOpenTypeContents(pdOpenType.bytes); err != nil {
+		return err
+	}
+	perTrace(2, fmt.Sprintf("Encoded OpenType %s", v.Type().String()))
+	return nil
+}
+
+// appendRegisteredOpenType encodes the value of the interface v as an open type, with
+// the parameters registered for its reference value in the object set of v.
+func (pd *perRawBitData) appendRegisteredOpenType(v reflect.Value, params fieldParameters) error {
+	if v.IsNil() {
+		return fmt.Errorf("aper: cannot marshal nil open type")
+	}
+	value := v.Elem()
+	if unknown, ok := value.Interface().(UnknownOpenType); ok {
+		perTrace(2, "Encoding unknown OpenType")
+		return pd.appendOpenTypeContents(unknown)
+	}
+	object, ok, err := getOpenTypeObject(v.Type(), params)
+	if err != nil {
+		return err
+	} else if params.globalReference {
+		return fmt.Errorf("open type %s of a global reference is not UnknownOpenType", value.Type())
+	} else if !ok {
+		return fmt.Errorf("open type reference value %d is not registered for %s", *params.referenceFieldValue,
+			v.Type())
+	} else if value.Type() != object.valueType {
+		return fmt.Errorf("open type %s does not match %s registered for reference value %d", value.Type(),
+			object.valueType, *params.referenceFieldValue)
+	}
+	return pd.appendOpenType(value, object.params)
+}
 
-	var byteOffset, partOfRawLength uint64
+func (pd *perRawBitData) appendOpenTypeContents(openTypeBytes []byte) error {
+	if len(openTypeBytes) == 0 {
+		// an empty encoding is replaced by a single zero octet (X.691 10.1.3)
+		openTypeBytes = make([]byte, 1)
+	}
+	// X.691 11.9.3.8: fragments of 16K, 32K, 48K or 64K octets are followed by the
+	// length of the rest, which is zero if there is nothing left
 	for {
-		if rawLength > 65536 {
-			partOfRawLength = 65536
-		} else if rawLength >= 16384 {
-			partOfRawLength = rawLength & 0xc000
-		} else {
-			partOfRawLength = rawLength
-		}
-		if err := pd.appendLength(-1, partOfRawLength); err != nil {
+		partLength := fragmentSize(uint64(len(openTypeBytes)))
+		if err := pd.appendLength(-1, partLength); err != nil {
 			return err
 		}
-		perTrace(2, fmt.Sprintf("Encoding Part of OpenType RawData size %d", partOfRawLength))
-		if partOfRawLength == 0 {
+		perTrace(2, fmt.Sprintf("Encoding Part of OpenType RawData size %d", partLength))
+		if partLength > 0 {
+			pd.bytes = append(pd.bytes, openTypeBytes[:partLength]...)
+			perTrace(1, perRawBitLog(partLength*8, len(pd.bytes), pd.bitsOffset, openTypeBytes[:partLength]))
+			perTrace(2, fmt.Sprintf("Encoded OpenType RawData (length = %d): 0x%0x", partLength,
+				openTypeBytes[:partLength]))
+		}
+		openTypeBytes = openTypeBytes[partLength:]
+		if partLength < 16384 {
 			return nil
 		}
-		pd.appendAlignBits()
-		pd.bytes = append(pd.bytes, openTypeBytes[byteOffset:byteOffset+partOfRawLength]...)
-		perTrace(1, perRawBitLog(partOfRawLength*8, len(pd.bytes), pd.bitsOffset, openTypeBytes))
-		perTrace(2, fmt.Sprintf("Encoded OpenType RawData (length = %d): 0x%0x", partOfRawLength,
-			openTypeBytes[byteOffset:byteOffset+partOfRawLength]))
-		rawLength -= partOfRawLength
-		if rawLength > 0 {
-			byteOffset += partOfRawLength
-		} else {
-			pd.appendAlignBits()
-			break
+	}
+}
+
+func (pd *perRawBitData) appendSequenceComponents(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters, indexes []int,
+) error {
+	structType := v.Type()
+	var optionalCount uint
+	var optionalPresents uint64
+
+	for _, i := range indexes {
+		field := v.FieldByIndex(fields[i].Index)
+		if structParams[i].defaultValue != nil {
+			optionalCount++
+			optionalPresents <<= 1
+			if isDefault, err := isDefaultValue(field, *structParams[i].defaultValue); err != nil {
+				return err
+			} else if !isDefault {
+				optionalPresents++
+			}
+		} else if structParams[i].optional {
+			optionalCount++
+			optionalPresents <<= 1
+			if !field.IsNil() {
+				optionalPresents++
+			}
+		} else if field.Type().Kind() == reflect.Ptr && field.IsNil() {
+			return fmt.Errorf("nil element in SEQUENCE type")
+		}
+	}
+	if optionalCount > 0 {
+		perTrace(2, fmt.Sprintf("putting optional(%d), optionalPresents is %0b", optionalCount, optionalPresents))
+		if err := pd.putBitsValue(optionalPresents, optionalCount); err != nil {
+			return err
 		}
 	}
 
-	perTrace(2, fmt.Sprintf("Encoded OpenType %s", v.Type().String()))
+	for _, i := range indexes {
+		pd.ancestors[len(pd.ancestors)-1].index = fields[i].Index
+		// optional or default
+		if structParams[i].isOptionalOrDefault() && optionalCount > 0 {
+			optionalCount--
+			if optionalPresents&(1<<optionalCount) == 0 {
+				perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and not present", fields[i].Name, structType))
+				continue
+			} else {
+				perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and present", fields[i].Name, structType))
+			}
+		}
+		// for open type reference
+		if structParams[i].openType {
+			if err := setReferenceFieldValue(pd.ancestors, &structParams[i]); err != nil {
+				return err
+			}
+		}
+		if err := pd.makeField(v.FieldByIndex(fields[i].Index), structParams[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendExtensionAdditions encodes the extension addition bitmap of a SEQUENCE
+// followed by each present addition as an open type. An extension addition group
+// is encoded as a SEQUENCE of the fields of the group (X.691 19.9). Additions kept
+// in the RawExtensions field are re-encoded verbatim at their own position.
+func (pd *perRawBitData) appendExtensionAdditions(v reflect.Value, fields []reflect.StructField,
+	structParams []fieldParameters, additions []extensionAddition,
+) error {
+	structType := v.Type()
+	var rawExtensions RawExtensions
+	if rawExtensionsIndex := getRawExtensionsIndex(fields); rawExtensionsIndex >= 0 {
+		rawExtensions = append(rawExtensions,
+			v.FieldByIndex(fields[rawExtensionsIndex].Index).Interface().(RawExtensions)...)
+		sort.Slice(rawExtensions, func(a, b int) bool { return rawExtensions[a].Index < rawExtensions[b].Index })
+	}
+	numAdditions := uint64(len(additions))
+	for j, rawExtension := range rawExtensions {
+		if rawExtension.Index < uint64(len(additions)) {
+			return fmt.Errorf("raw extension %d is a declared extension addition of %s", rawExtension.Index, structType)
+		} else if j > 0 && rawExtension.Index == rawExtensions[j-1].Index {
+			return fmt.Errorf("raw extension %d of %s is duplicated", rawExtension.Index, structType)
+		}
+		numAdditions = rawExtension.Index + 1
+	}
+
+	perTrace(2, fmt.Sprintf("Encoding %d bits of extension addition bitmap", numAdditions))
+	if err := pd.appendNormallySmallLength(numAdditions); err != nil {
+		return err
+	}
+	rawExtensionsOffset := 0
+	for j := uint64(0); j < numAdditions; j++ {
+		var bit uint64
+		if j < uint64(len(additions)) {
+			if additions[j].isPresent(v, fields, structParams) {
+				bit = 1
+			}
+		} else if rawExtensions[rawExtensionsOffset].Index == j {
+			bit = 1
+			rawExtensionsOffset++
+		}
+		if err := pd.putBitsValue(bit, 1); err != nil {
+			return err
+		}
+	}
+	for _, addition := range additions {
+		if !addition.isPresent(v, fields, structParams) {
+			continue
+		}
+		if addition.group {
+			perTrace(3, fmt.Sprintf("Extension addition group of %s with %d fields is present", structType,
+				len(addition.fields)))
+			pdGroup := &perRawBitData{[]byte(""), 0, pd.ancestors}
+			if err := pdGroup.appendSequenceComponents(v, fields, structParams, addition.fields); err != nil {
+				return err
+			}
+			if err := pd.appendOpenTypeContents(pdGroup.bytes); err != nil {
+				return err
+			}
+			continue
+		}
+		i := addition.fields[0]
+		pd.ancestors[len(pd.ancestors)-1].index = fields[i].Index
+		perTrace(3, fmt.Sprintf("Field \"%s\" in %s is extension addition and present", fields[i].Name, structType))
+		if structParams[i].openType {
+			if err := setReferenceFieldValue(pd.ancestors, &structParams[i]); err != nil {
+				return err
+			}
+		}
+		if err := pd.appendOpenType(v.FieldByIndex(fields[i].Index), structParams[i]); err != nil {
+			return err
+		}
+	}
+	for _, rawExtension := range rawExtensions {
+		perTrace(3, fmt.Sprintf("Raw extension addition %d of %s is present", rawExtension.Index, structType))
+		if err := pd.appendOpenTypeContents(rawExtension.Bytes); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -637,6 +1110,9 @@ func (pd *perRawBitData) makeField(v reflect.Value, params fieldParameters) erro
 	if !v.IsValid() {
 		return fmt.Errorf("aper: cannot marshal nil value")
 	}
+	if v.Kind() == reflect.Interface && params.openType {
+		return pd.appendRegisteredOpenType(v, params)
+	}
 	// If the field is an interface{} then recurse into it.
 	if v.Kind() == reflect.Interface && v.Type().NumMethod() == 0 {
 		return pd.makeField(v.Elem(), params)
@@ -653,64 +1129,73 @@ func (pd *perRawBitData) makeField(v reflect.Value, params fieldParameters) erro
 			params.sizeUpperBound)
 		return err
 	case ObjectIdentifierType:
-		return fmt.Errorf("unsupport ObjectIdenfier type")
+		perTrace(2, fmt.Sprintf("Encoding OBJECT IDENTIFIER : %s", ObjectIdentifier(v.Bytes())))
+		err := pd.appendOctetString(v.Bytes(), false, nil, nil)
+		return err
+	case RelativeOIDType:
+		perTrace(2, fmt.Sprintf("Encoding RELATIVE-OID : %s", RelativeOID(v.Bytes())))
+		err := pd.appendOctetString(v.Bytes(), false, nil, nil)
+		return err
 	case OctetStringType:
 		err := pd.appendOctetString(v.Bytes(), params.sizeExtensible, params.sizeLowerBound, params.sizeUpperBound)
 		return err
 	case EnumeratedType:
 		err := pd.appendEnumerated(v.Uint(), params.valueExtensible, params.valueLowerBound, params.valueUpperBound)
 		return err
+	case NullType:
+		perTrace(2, "Encoded NULL")
+		return nil
+	case TimeType:
+		perTrace(2, fmt.Sprintf("Encoding TIME : \"%s\"", v.String()))
+		return pd.appendCharacterString(v.String(), fieldParameters{stringType: stringTypeVisible})
+	case DateType:
+		perTrace(2, fmt.Sprintf("Encoding DATE : %s", v.Interface()))
+		return pd.makeField(reflect.ValueOf(newDateEncoding(v.Interface().(Date))), fieldParameters{})
+	case bigIntType:
+		return pd.appendBigInteger(bigIntOf(v), params.valueExtensible, params.bigValueLowerBound,
+			params.bigValueUpperBound)
+	case goTimeType:
+		timeString, err := formatTime(v.Interface().(time.Time), params.timeFormat)
+		if err != nil {
+			return err
+		}
+		perTrace(2, fmt.Sprintf("Encoding %s : \"%s\"", params.timeFormat, timeString))
+		return pd.appendCharacterString(timeString, fieldParameters{stringType: stringTypeVisible})
 	}
 	switch val := v; val.Kind() {
 	case reflect.Bool:
 		err := pd.appendBool(v.Bool())
 		return err
-	case reflect.Int, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		err := pd.appendInteger(v.Int(), params.valueExtensible, params.valueLowerBound, params.valueUpperBound)
 		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// unsigned values and their bounds may exceed MaxInt64
+		return pd.appendBigInteger(new(big.Int).SetUint64(v.Uint()), params.valueExtensible,
+			params.bigValueLowerBound, params.bigValueUpperBound)
+	case reflect.Float32, reflect.Float64:
+		err := pd.appendReal(v.Float())
+		return err
 
 	case reflect.Struct:
 
 		structType := fieldType
+		structFields := getStructFields(structType)
 		var structParams []fieldParameters
-		var optionalCount uint
-		var optionalPresents uint64
 		var sequenceType bool
-		// struct extensive TODO: support extensed type
-		if params.valueExtensible {
-			perTrace(2, fmt.Sprintf("Encoding Value Extensive Bit : %t", false))
-			if err := pd.putBitsValue(0, 1); err != nil {
-				return err
-			}
-		}
 		sequenceType = (structType.NumField() <= 0 || structType.Field(0).Name != PRESENT)
-		// pass tag for optional
-		for i := 0; i < structType.NumField(); i++ {
-			if structType.Field(i).PkgPath != "" {
-				return fmt.Errorf("struct contains unexported fields : %s", structType.Field(i).PkgPath)
-			}
-			tempParams := parseFieldParameters(structType.Field(i).Tag.Get("aper"))
-			if sequenceType {
-				// for optional flag
-				if tempParams.optional {
-					optionalCount++
-					optionalPresents <<= 1
-					if !v.Field(i).IsNil() {
-						optionalPresents++
-					}
-				} else if v.Field(i).Type().Kind() == reflect.Ptr && v.Field(i).IsNil() {
-					return fmt.Errorf("nil element in SEQUENCE type")
+		for _, field := range structFields {
+			if field.PkgPath != "" {
+				return fmt.Errorf("struct contains unexported fields : %s", field.PkgPath)
+			}
+			tempParams := parseFieldParameters(field.Tag.Get("aper"))
+			if tempParams.defaultValue != nil {
+				if _, err := getDefaultValue(field.Type, *tempParams.defaultValue); err != nil {
+					return err
 				}
 			}
-
 			structParams = append(structParams, tempParams)
 		}
-		if optionalCount > 0 {
-			perTrace(2, fmt.Sprintf("putting optional(%d), optionalPresents is %0b", optionalCount, optionalPresents))
-			if err := pd.putBitsValue(optionalPresents, optionalCount); err != nil {
-				return err
-			}
-		}
 
 		// CHOICE or OpenType
 		if !sequenceType {
@@ -720,11 +1205,18 @@ func (pd *perRawBitData) makeField(v reflect.Value, params fieldParameters) erro
 			} else if present >= structType.NumField() {
 				return fmt.Errorf("present is bigger than number of struct field")
 			} else if params.openType {
-				if params.referenceFieldValue == nil {
+				if params.referenceFieldValue == nil && !params.globalReference {
 					return fmt.Errorf("openType reference value is empty")
 				}
-				refValue := *params.referenceFieldValue
 
+				if structType.Field(present).Type == UnknownOpenTypeType {
+					perTrace(2, "Encoding unknown OpenType")
+					return pd.appendOpenTypeContents(val.Field(present).Bytes())
+				}
+				if params.globalReference {
+					return fmt.Errorf("open type of a global reference is not UnknownOpenType")
+				}
+				refValue := *params.referenceFieldValue
 				if structParams[present].referenceFieldValue == nil || *structParams[present].referenceFieldValue != refValue {
 					return fmt.Errorf("reference value and present reference value is not match")
 				}
@@ -732,56 +1224,82 @@ func (pd *perRawBitData) makeField(v reflect.Value, params fieldParameters) erro
 				if err := pd.appendOpenType(val.Field(present), structParams[present]); err != nil {
 					return err
 				}
+			} else if structType.Field(present).Type == RawExtensionsType {
+				return pd.appendChoiceRawExtension(val.Field(present).Interface().(RawExtensions), params.valueExtensible,
+					params.valueUpperBound)
 			} else {
 				if err := pd.appendChoiceIndex(present, params.valueExtensible, params.valueUpperBound); err != nil {
 					return err
 				}
-				if err := pd.makeField(val.Field(present), structParams[present]); err != nil {
+				if int64(present-1) > *params.valueUpperBound {
+					// alternatives outside the extension root are encoded as open types
+					if err := pd.appendOpenType(val.Field(present), structParams[present]); err != nil {
+						return err
+					}
+				} else if err := pd.makeField(val.Field(present), structParams[present]); err != nil {
 					return err
 				}
 			}
 			return nil
 		}
 
-		for i := 0; i < structType.NumField(); i++ {
-			// optional
-			if structParams[i].optional && optionalCount > 0 {
-				optionalCount--
-				if optionalPresents&(1<<optionalCount) == 0 {
-					perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and not present", structType.Field(i).Name, structType))
-					continue
-				} else {
-					perTrace(3, fmt.Sprintf("Field \"%s\" in %s is OPTIONAL and present", structType.Field(i).Name, structType))
-				}
+		root, additions, err := splitExtensionAdditions(structFields, structParams)
+		if err != nil {
+			return err
+		} else if len(additions) > 0 && !params.valueExtensible {
+			return fmt.Errorf("extension addition in non-extensible SEQUENCE %s", structType)
+		}
+		if params.set {
+			if err := sortSetComponents(structType, structFields, structParams, root); err != nil {
+				return err
 			}
-			// for open type reference
-			if structParams[i].openType {
-				fieldName := structParams[i].referenceFieldName
-				var index int
-				for index = 0; index < i; index++ {
-					if structType.Field(index).Name == fieldName {
-						break
-					}
-				}
-				if index == i {
-					return fmt.Errorf("open type is not reference to the other field in the struct")
-				}
-				structParams[i].referenceFieldValue = new(int64)
-				if value, err := getReferenceFieldValue(val.Field(index)); err != nil {
-					return err
-				} else {
-					*structParams[i].referenceFieldValue = value
-				}
+		}
+		extensed := false
+		for _, addition := range additions {
+			if addition.isPresent(v, structFields, structParams) {
+				extensed = true
+				break
+			}
+		}
+		if rawExtensionsIndex := getRawExtensionsIndex(structFields); rawExtensionsIndex >= 0 &&
+			v.FieldByIndex(structFields[rawExtensionsIndex].Index).Len() > 0 {
+			if !params.valueExtensible {
+				return fmt.Errorf("raw extensions in non-extensible SEQUENCE %s", structType)
 			}
-			if err := pd.makeField(val.Field(i), structParams[i]); err != nil {
+			extensed = true
+		}
+		if params.valueExtensible {
+			perTrace(2, fmt.Sprintf("Encoding Value Extensive Bit : %t", extensed))
+			var bit uint64
+			if extensed {
+				bit = 1
+			}
+			if err := pd.putBitsValue(bit, 1); err != nil {
 				return err
 			}
 		}
+		pd.ancestors = append(pd.ancestors, newSequenceAncestor(val, structFields, root, params.set))
+		defer func() { pd.ancestors = pd.ancestors[:len(pd.ancestors)-1] }()
+		if err := pd.appendSequenceComponents(val, structFields, structParams, root); err != nil {
+			return err
+		}
+		if params.valueExtensible && extensed {
+			return pd.appendExtensionAdditions(val, structFields, structParams, additions)
+		}
 		return nil
 	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.Uint8 {
+			// any slice of bytes is an OCTET STRING rather than a SEQUENCE OF INTEGER
+			return pd.appendOctetString(v.Bytes(), params.sizeExtensible, params.sizeLowerBound, params.sizeUpperBound)
+		}
 		err := pd.parseSequenceOf(v, params)
 		return err
 	case reflect.String:
+		if params.stringType.isKnownMultiplier() {
+			return pd.appendCharacterString(v.String(), params)
+		} else if params.stringType == stringTypeUTF8 {
+			return pd.appendUTF8String(v.String(), params)
+		}
 		printableString := v.String()
 		perTrace(2, fmt.Sprintf("Encoding PrintableString : \"%s\" using Octet String decoding method", printableString))
 		err := pd.appendOctetString([]byte(printableString), params.sizeExtensible, params.sizeLowerBound,
@@ -799,7 +1317,7 @@ func Marshal(val interface{}) ([]byte, error) {
 // MarshalWithParams allows field parameters to be specified for the
 // top-level element. The form of the params is the same as the field tags.
 func MarshalWithParams(val interface{}, params string) ([]byte, error) {
-	pd := &perRawBitData{[]byte(""), 0}
+	pd := &perRawBitData{[]byte(""), 0, nil}
 	err := pd.makeField(reflect.ValueOf(val), parseFieldParameters(params))
 	if err != nil {
 		return nil, err